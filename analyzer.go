@@ -1,6 +1,7 @@
 package lifecycle
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -19,6 +20,7 @@ type Analyzer struct {
 	Buildpacks []*Buildpack
 	AppDir     string
 	LayersDir  string
+	Context    context.Context
 	In         []byte
 	Out, Err   *log.Logger
 }
@@ -50,6 +52,9 @@ func (a *Analyzer) analyze(metadata AppImageMetadata) error {
 	}
 
 	for groupBP := range groupBPs {
+		if err := checkContext(a.Context); err != nil {
+			return err
+		}
 		analyzedDirectory := analyzedBuildPackDirectory{metadata, a.LayersDir, groupBP}
 
 		layers, err := analyzedDirectory.allLayers()
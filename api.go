@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultBuildpackAPI is assumed for buildpacks that do not declare an api in buildpack.toml.
+const DefaultBuildpackAPI = "0.1"
+
+// SupportedBuildpackAPIs lists the Buildpack API versions implemented by this lifecycle.
+var SupportedBuildpackAPIs = []string{"0.1", "0.2"}
+
+type APIVersion struct {
+	Major, Minor int
+}
+
+func ParseAPIVersion(s string) (APIVersion, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return APIVersion{}, fmt.Errorf("invalid API version '%s': must be <major>.<minor>", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return APIVersion{}, fmt.Errorf("invalid API version '%s': major version must be a number", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return APIVersion{}, fmt.Errorf("invalid API version '%s': minor version must be a number", s)
+	}
+	return APIVersion{Major: major, Minor: minor}, nil
+}
+
+func (v APIVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+func (v APIVersion) Less(o APIVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+// SupportedBy returns true if a buildpack implementing v can be run by a
+// lifecycle implementing o. Pre-1.0 versions must match exactly, while
+// later versions must share a major version and not be newer than o.
+func (v APIVersion) SupportedBy(o APIVersion) bool {
+	if v.Major != o.Major {
+		return false
+	}
+	if v.Major == 0 {
+		return v.Minor == o.Minor
+	}
+	return v.Minor <= o.Minor
+}
+
+var api02 = APIVersion{Major: 0, Minor: 2}
+
+func (bp *Buildpack) api() string {
+	if bp.API == "" {
+		return DefaultBuildpackAPI
+	}
+	return bp.API
+}
+
+func (bp *Buildpack) apiVersion() APIVersion {
+	v, _ := ParseAPIVersion(bp.api())
+	return v
+}
+
+func checkBuildpackAPI(bp *Buildpack) error {
+	v, err := ParseAPIVersion(bp.api())
+	if err != nil {
+		return err
+	}
+	for _, supported := range SupportedBuildpackAPIs {
+		if s, err := ParseAPIVersion(supported); err == nil && v.SupportedBy(s) {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"buildpack '%s@%s' requires buildpack API %s, but this lifecycle supports %s",
+		bp.ID, bp.Version, bp.api(), strings.Join(SupportedBuildpackAPIs, ", "),
+	)
+}
+
+// buildpackEnv returns the environment variables provided to the
+// executables of buildpacks that implement API 0.2 or later.
+func (bp *Buildpack) buildpackEnv() []string {
+	if bp.apiVersion().Less(api02) {
+		return nil
+	}
+	dir, err := filepath.Abs(bp.Dir)
+	if err != nil {
+		dir = bp.Dir
+	}
+	return []string{"CNB_BUILDPACK_DIR=" + dir}
+}
+
+// processEnv returns the environment for the executables of bp, given the
+// environment of the lifecycle. The platform env is applied to it unless the
+// buildpack sets clear-env.
+func (bp *Buildpack) processEnv(environ []string, platformDir string) ([]string, error) {
+	environ = append(environ, bp.buildpackEnv()...)
+	if bp.ClearEnv {
+		return environ, nil
+	}
+	return withEnvDir(environ, filepath.Join(platformDir, "env"))
+}
@@ -2,12 +2,14 @@ package lifecycle
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -19,6 +21,9 @@ type Builder struct {
 	Env         BuildEnv
 	Buildpacks  []*Buildpack
 	Plan        Plan
+	Context     context.Context
+	Timeout     time.Duration
+	GracePeriod time.Duration
 	Out, Err    io.Writer
 }
 
@@ -28,9 +33,17 @@ type BuildEnv interface {
 	List() []string
 }
 
+// Process is a process type contributed in launch.toml. Unless Direct is
+// set, Command is run by the launcher's shell (bash by default) after
+// sourcing profile.d scripts, followed by Args as quoted arguments. If Direct
+// is set, Command is executed without a shell with Args as its arguments.
+// WorkingDir defaults to the app dir, and relative paths are resolved against it.
 type Process struct {
-	Type    string `toml:"type"`
-	Command string `toml:"command"`
+	Type       string   `toml:"type"`
+	Command    string   `toml:"command"`
+	Args       []string `toml:"args,omitempty"`
+	Direct     bool     `toml:"direct,omitempty"`
+	WorkingDir string   `toml:"working-dir,omitempty"`
 }
 
 type LaunchTOML struct {
@@ -46,6 +59,10 @@ type BuildMetadata struct {
 }
 
 func (b *Builder) Build() (*BuildMetadata, error) {
+	return b.run("build")
+}
+
+func (b *Builder) run(executable string) (*BuildMetadata, error) {
 	platformDir, err := filepath.Abs(b.PlatformDir)
 	if err != nil {
 		return nil, err
@@ -67,6 +84,12 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 	procMap := processMap{}
 	plan := copyPlan(b.Plan)
 	bom := copyPlan(b.Plan)
+	provided := map[string]bool{}
+	for _, bp := range b.Buildpacks {
+		for _, p := range bp.Provides {
+			provided[p] = true
+		}
+	}
 	var buildpackIDs []string
 	for _, bp := range b.Buildpacks {
 		bpDirName := bp.EscapedID()
@@ -85,20 +108,22 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 			return nil, err
 		}
 		planIn := &bytes.Buffer{}
-		if err := toml.NewEncoder(planIn).Encode(plan); err != nil {
+		if err := toml.NewEncoder(planIn).Encode(plan.forBuildpack(bp, provided)); err != nil {
 			return nil, err
 		}
-		buildPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", "build"))
+		buildPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", executable))
 		if err != nil {
 			return nil, err
 		}
 		cmd := exec.Command(buildPath, bpLayersDir, platformDir, bpPlanPath)
-		cmd.Env = b.Env.List()
+		if cmd.Env, err = bp.processEnv(b.Env.List(), platformDir); err != nil {
+			return nil, err
+		}
 		cmd.Dir = appDir
 		cmd.Stdin = planIn
 		cmd.Stdout = b.Out
 		cmd.Stderr = b.Err
-		if err := cmd.Run(); err != nil {
+		if err := runCommand(cmd, commandConfig{ctx: b.Context, timeout: b.Timeout, grace: b.GracePeriod}); err != nil {
 			return nil, err
 		}
 		if err := setupEnv(b.Env, bpLayersDir); err != nil {
@@ -188,6 +213,27 @@ func (m processMap) list() []Process {
 	return procs
 }
 
+// forBuildpack returns the entries of the plan that bp is responsible for:
+// the entries it provides, and any entries that no buildpack provides.
+func (p Plan) forBuildpack(bp *Buildpack, provided map[string]bool) Plan {
+	out := Plan{}
+	for k, v := range p {
+		if !provided[k] || contains(bp.Provides, k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func copyPlan(m Plan) Plan {
 	out := Plan{}
 	for k, v := range m {
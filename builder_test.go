@@ -2,6 +2,7 @@ package lifecycle_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +11,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/golang/mock/gomock"
@@ -157,6 +160,47 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should carry process args, direct and working dir into the metadata", func() {
+				bpDir := filepath.Join(tmpDir, "buildpack")
+				mkdir(t, filepath.Join(bpDir, "bin"))
+				mkfile(t, `#!/usr/bin/env bash
+cat > "$1/launch.toml" <<EOF
+[[processes]]
+type = "web"
+command = "/bin/server"
+args = ["--port", "8080"]
+direct = true
+working-dir = "public"
+EOF
+`, filepath.Join(bpDir, "bin", "build"))
+				builder.Buildpacks = []*lifecycle.Buildpack{
+					{ID: "buildpack1-id", Dir: bpDir},
+					{ID: "buildpack2-id", Dir: bpDir},
+				}
+
+				metadata, err := builder.Build()
+				if err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				metadataPath := filepath.Join(tmpDir, "config", "metadata.toml")
+				if err := lifecycle.WriteTOML(metadataPath, metadata); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				var written lifecycle.BuildMetadata
+				if _, err := toml.DecodeFile(metadataPath, &written); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(written.Processes, []lifecycle.Process{{
+					Type:       "web",
+					Command:    "/bin/server",
+					Args:       []string{"--port", "8080"},
+					Direct:     true,
+					WorkingDir: "public",
+				}}); s != "" {
+					t.Fatalf("Unexpected processes:\n%s\n", s)
+				}
+			})
+
 			it("should return build metadata when processes are not present", func() {
 				mkfile(t, "test", filepath.Join(appDir, "skip-processes"))
 				metadata, err := builder.Build()
@@ -192,6 +236,29 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				)
 			})
 
+			it("should provide the platform env to buildpacks that do not clear it", func() {
+				bpDir := filepath.Join(tmpDir, "buildpack")
+				mkdir(t, filepath.Join(bpDir, "bin"))
+				mkfile(t, "#!/usr/bin/env bash\necho -n \"${BP_NODE_VERSION:-none}\" > \"node${ID}\"\n",
+					filepath.Join(bpDir, "bin", "build"),
+				)
+				mkfile(t, "10.x", filepath.Join(platformDir, "env", "BP_NODE_VERSION"))
+				builder.Buildpacks = []*lifecycle.Buildpack{
+					{ID: "buildpack1-id", Dir: bpDir},
+					{ID: "buildpack2-id", Dir: bpDir, ClearEnv: true},
+				}
+
+				if _, err := builder.Build(); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(rdfile(t, filepath.Join(appDir, "node1")), "10.x"); s != "" {
+					t.Fatalf("Unexpected platform env:\n%s\n", s)
+				}
+				if s := cmp.Diff(rdfile(t, filepath.Join(appDir, "node2")), "none"); s != "" {
+					t.Fatalf("Unexpected platform env:\n%s\n", s)
+				}
+			})
+
 			it("should connect stdout and stdin to the terminal", func() {
 				if _, err := builder.Build(); err != nil {
 					t.Fatalf("Error: %s\n", err)
@@ -204,6 +271,28 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should provide the buildpack dir to buildpacks implementing API 0.2", func() {
+				bpDir := filepath.Join(tmpDir, "buildpack")
+				mkdir(t, filepath.Join(bpDir, "bin"))
+				mkfile(t, "#!/usr/bin/env bash\necho -n \"${CNB_BUILDPACK_DIR:-none}\" > \"bp-dir${ID}\"\n",
+					filepath.Join(bpDir, "bin", "build"),
+				)
+				builder.Buildpacks = []*lifecycle.Buildpack{
+					{ID: "buildpack1-id", Dir: bpDir},
+					{ID: "buildpack2-id", Dir: bpDir, API: "0.2"},
+				}
+
+				if _, err := builder.Build(); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(rdfile(t, filepath.Join(appDir, "bp-dir1")), "none"); s != "" {
+					t.Fatalf("Unexpected buildpack dir:\n%s\n", s)
+				}
+				if s := cmp.Diff(rdfile(t, filepath.Join(appDir, "bp-dir2")), bpDir); s != "" {
+					t.Fatalf("Unexpected buildpack dir:\n%s\n", s)
+				}
+			})
+
 			it("should provide a subset of the build plan to each buildpack", func() {
 				if _, err := builder.Build(); err != nil {
 					t.Fatalf("Error: %s\n", err)
@@ -229,6 +318,31 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 					filepath.Join(appDir, "plan2.toml"),
 				)
 			})
+
+			it("should only provide entries that a buildpack provides to that buildpack", func() {
+				builder.Buildpacks[0].Provides = []string{"dep2"}
+				builder.Buildpacks[1].Provides = []string{"dep1", "dep2-keep"}
+				if _, err := builder.Build(); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				testPlan(t,
+					lifecycle.Plan{
+						"dep1-keep":    {"v": "2"},
+						"dep1-replace": {"v": "3"},
+						"dep2":         {"v": "4"},
+						"dep2-replace": {"v": "6"},
+					},
+					filepath.Join(appDir, "plan1.toml"),
+				)
+				testPlan(t,
+					lifecycle.Plan{
+						"dep1":         {"v": "1"},
+						"dep2-keep":    {"v": "5"},
+						"dep2-replace": {"v": "6"},
+					},
+					filepath.Join(appDir, "plan2.toml"),
+				)
+			})
 		})
 
 		when("building fails", func() {
@@ -270,6 +384,66 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should kill the build when it exceeds the timeout", func() {
+				env.EXPECT().List().Return([]string{"ID=1"})
+				sleeperDir := filepath.Join(tmpDir, "sleeper")
+				mkdir(t, filepath.Join(sleeperDir, "bin"))
+				mkfile(t, "#!/usr/bin/env bash\nsleep 60 &\nwait\n",
+					filepath.Join(sleeperDir, "bin", "build"),
+				)
+				builder.Buildpacks = []*lifecycle.Buildpack{{ID: "sleeper", Dir: sleeperDir}}
+				builder.Timeout = 100 * time.Millisecond
+
+				start := time.Now()
+				_, err := builder.Build()
+				if _, ok := err.(*lifecycle.TimeoutError); !ok {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+				if elapsed := time.Since(start); elapsed > 30*time.Second {
+					t.Fatalf("Build was not killed after %s\n", elapsed)
+				}
+			})
+
+			it("should forward interrupts to the build and return once it exits", func() {
+				env.EXPECT().List().Return([]string{"ID=1"})
+				trapperDir := filepath.Join(tmpDir, "trapper")
+				mkdir(t, filepath.Join(trapperDir, "bin"))
+				mkfile(t, "#!/usr/bin/env bash\ntrap 'echo term > \"$2/terminated\"; exit 1' TERM\nsleep 60 &\nwait\n",
+					filepath.Join(trapperDir, "bin", "build"),
+				)
+				ctx, cancel := context.WithCancel(context.Background())
+				builder.Buildpacks = []*lifecycle.Buildpack{{ID: "trapper", Dir: trapperDir}}
+				builder.Context = ctx
+				builder.GracePeriod = 30 * time.Second
+
+				time.AfterFunc(500*time.Millisecond, cancel)
+				_, err := builder.Build()
+				if err, ok := err.(*lifecycle.InterruptError); !ok {
+					t.Fatalf("Incorrect error: %s\n", err)
+				} else if err.Signal != syscall.SIGTERM {
+					t.Fatalf("Unexpected signal: %s\n", err.Signal)
+				}
+				if s := cmp.Diff(rdfile(t, filepath.Join(platformDir, "terminated")), "term\n"); s != "" {
+					t.Fatalf("Unexpected output:\n%s\n", s)
+				}
+			})
+
+			it("should not start the build after an interrupt", func() {
+				env.EXPECT().List().Return([]string{"ID=1"})
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				builder.Context = ctx
+
+				if _, err := builder.Build(); err == nil {
+					t.Fatal("Expected error.\n")
+				} else if _, ok := err.(*lifecycle.InterruptError); !ok {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+				if _, err := os.Stat(filepath.Join(appDir, "plan1.toml")); !os.IsNotExist(err) {
+					t.Fatalf("Expected build not to run: %v\n", err)
+				}
+			})
+
 			when("modifying the env fails", func() {
 				var appendErr error
 
@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"errors"
+	"io"
+
+	"github.com/buildpack/lifecycle/image"
+)
+
+var errCacheCommitted = errors.New("cache cannot be modified after commit")
+
+type Cache interface {
+	Name() string
+	AddLayer(sha, tarPath string) error
+	ReuseLayer(sha string) error
+	RetrieveLayer(sha string) (io.ReadCloser, error)
+	SetMetadata(metadata CacheMetadata) error
+	RetrieveMetadata() (CacheMetadata, error)
+	Commit() error
+}
+
+// NewCache returns a cache backed by cacheImageRef, read from the docker
+// daemon if useDaemon is set, or by cacheDir if no cache image is given.
+func NewCache(cacheDir, cacheImageRef string, useDaemon bool) (Cache, error) {
+	if cacheImageRef == "" {
+		return NewVolumeCache(cacheDir)
+	}
+	factory, err := image.DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	var origImage, newImage image.Image
+	if useDaemon {
+		origImage, err = factory.NewLocal(cacheImageRef, false)
+		if err != nil {
+			return nil, err
+		}
+		newImage = factory.NewEmptyLocal(cacheImageRef)
+	} else {
+		origImage, err = factory.NewRemote(cacheImageRef)
+		if err != nil {
+			return nil, err
+		}
+		newImage = factory.NewEmptyRemote(cacheImageRef)
+	}
+	return NewImageCache(origImage, newImage), nil
+}
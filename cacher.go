@@ -0,0 +1,96 @@
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+type Cacher struct {
+	Buildpacks   []*Buildpack
+	ArtifactsDir string
+	Context      context.Context
+	Out, Err     *log.Logger
+	UID, GID     int
+}
+
+func (c *Cacher) Cache(layersDir string, cacheStore Cache) error {
+	layersDir, err := filepath.Abs(layersDir)
+	if err != nil {
+		return err
+	}
+
+	origMetadata, err := cacheStore.RetrieveMetadata()
+	if err != nil {
+		return errors.Wrap(err, "metadata for previous cache")
+	}
+
+	var metadata CacheMetadata
+	for _, bp := range c.Buildpacks {
+		bpMetadata := BuildpackMetadata{ID: bp.ID, Version: bp.Version, Layers: map[string]LayerMetadata{}}
+		origLayers := map[string]LayerMetadata{}
+		if origBP, ok := cacheMetadata(bp.ID, origMetadata); ok {
+			origLayers = origBP.Layers
+		}
+		tomls, err := filepath.Glob(filepath.Join(layersDir, bp.EscapedID(), "*.toml"))
+		if err != nil {
+			return errors.Wrap(err, "finding layer tomls")
+		}
+		for _, tomlFile := range tomls {
+			if filepath.Base(tomlFile) == "launch.toml" {
+				continue
+			}
+			layerDir := strings.TrimSuffix(tomlFile, ".toml")
+			layerName := filepath.Base(layerDir)
+			var layer LayerMetadata
+			if _, err := toml.DecodeFile(tomlFile, &layer); err != nil {
+				return errors.Wrapf(err, "read metadata for layer '%s/%s'", bp.ID, layerName)
+			}
+			// Launch layers are exported to the image, so only layers needed
+			// at build time alone are stored in the cache.
+			if !layer.Cache || layer.Launch {
+				continue
+			}
+			if _, err := os.Stat(layerDir); os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := checkContext(c.Context); err != nil {
+				return err
+			}
+			layer.SHA, err = writeLayerTar(c.ArtifactsDir, layersDir, layerDir, c.UID, c.GID)
+			if err != nil {
+				return errors.Wrapf(err, "exporting tar for layer '%s/%s'", bp.ID, layerName)
+			}
+			if origLayers[layerName].SHA == layer.SHA {
+				c.Out.Printf("reusing cached layer '%s/%s' with diffID '%s'\n", bp.ID, layerName, layer.SHA)
+				if err := cacheStore.ReuseLayer(layer.SHA); err != nil {
+					return errors.Wrapf(err, "reusing layer '%s/%s'", bp.ID, layerName)
+				}
+			} else {
+				c.Out.Printf("caching layer '%s/%s' with diffID '%s'\n", bp.ID, layerName, layer.SHA)
+				tarPath := filepath.Join(c.ArtifactsDir, layerTarName(layer.SHA))
+				if err := cacheStore.AddLayer(layer.SHA, tarPath); err != nil {
+					return errors.Wrapf(err, "caching layer '%s/%s'", bp.ID, layerName)
+				}
+			}
+			bpMetadata.Layers[layerName] = layer
+		}
+		metadata.Buildpacks = append(metadata.Buildpacks, bpMetadata)
+	}
+
+	if err := cacheStore.SetMetadata(metadata); err != nil {
+		return errors.Wrap(err, "setting cache metadata")
+	}
+	c.Out.Printf("committing cache '%s'\n", cacheStore.Name())
+	if err := cacheStore.Commit(); err != nil {
+		return errors.Wrap(err, "committing cache")
+	}
+	return nil
+}
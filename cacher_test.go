@@ -0,0 +1,167 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	h "github.com/buildpack/lifecycle/testhelpers"
+)
+
+func TestCacher(t *testing.T) {
+	spec.Run(t, "Cacher", testCacher, spec.Report(report.Terminal{}))
+}
+
+func testCacher(t *testing.T, when spec.G, it spec.S) {
+	var (
+		cacher         *lifecycle.Cacher
+		stdout, stderr *bytes.Buffer
+		tmpDir         string
+		layersDir      string
+		cacheDir       string
+		cacheStore     lifecycle.Cache
+		uid            = 1234
+		gid            = 4321
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cacher")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		layersDir = filepath.Join(tmpDir, "layers")
+		cacheDir = filepath.Join(tmpDir, "cache")
+		artifactsDir := filepath.Join(tmpDir, "artifacts")
+		mkdir(t, layersDir, cacheDir, artifactsDir)
+		cacheStore, err = lifecycle.NewVolumeCache(cacheDir)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		cacher = &lifecycle.Cacher{
+			Buildpacks:   []*lifecycle.Buildpack{{ID: "buildpack.id"}, {ID: "other.buildpack.id"}},
+			ArtifactsDir: artifactsDir,
+			Out:          log.New(stdout, "", 0),
+			Err:          log.New(stderr, "", 0),
+			UID:          uid,
+			GID:          gid,
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Cache", func() {
+		it.Before(func() {
+			mkdir(t,
+				filepath.Join(layersDir, "buildpack.id", "cache-layer"),
+				filepath.Join(layersDir, "buildpack.id", "launch-layer"),
+				filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer"),
+			)
+			mkfile(t, "cached", filepath.Join(layersDir, "buildpack.id", "cache-layer", "file"))
+			mkfile(t, "launched", filepath.Join(layersDir, "buildpack.id", "launch-layer", "file"))
+			mkfile(t, "both", filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer", "file"))
+			mkfile(t, "build = true\ncache = true\n[metadata]\n  key = \"val\"",
+				filepath.Join(layersDir, "buildpack.id", "cache-layer.toml"),
+			)
+			mkfile(t, "launch = true",
+				filepath.Join(layersDir, "buildpack.id", "launch-layer.toml"),
+			)
+			mkfile(t, "launch = true\ncache = true",
+				filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer.toml"),
+				filepath.Join(layersDir, "other.buildpack.id", "reused-layer.toml"),
+			)
+			mkfile(t, "[[processes]]\n  type = \"web\"\n  command = \"cache = true\"",
+				filepath.Join(layersDir, "buildpack.id", "launch.toml"),
+			)
+		})
+
+		it("should store cached build layers and their metadata", func() {
+			h.AssertNil(t, cacher.Cache(layersDir, cacheStore))
+
+			cacheSHA := h.ComputeSHA256ForRelativePath(t, layersDir, filepath.Join(layersDir, "buildpack.id", "cache-layer"), uid, gid)
+			bothSHA := h.ComputeSHA256ForRelativePath(t, layersDir, filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer"), uid, gid)
+			testExists(t, filepath.Join(cacheDir, "committed", cacheSHA+".tar"))
+			if _, err := os.Stat(filepath.Join(cacheDir, "committed", bothSHA+".tar")); !os.IsNotExist(err) {
+				t.Fatalf("Expected launch layer not to be cached: %s\n", err)
+			}
+
+			var metadata lifecycle.CacheMetadata
+			h.AssertNil(t, json.Unmarshal([]byte(rdfile(t, filepath.Join(cacheDir, "committed", "metadata.json"))), &metadata))
+			h.AssertEq(t, metadata, lifecycle.CacheMetadata{
+				Buildpacks: []lifecycle.BuildpackMetadata{
+					{
+						ID: "buildpack.id",
+						Layers: map[string]lifecycle.LayerMetadata{
+							"cache-layer": {
+								SHA:   "sha256:" + cacheSHA,
+								Data:  map[string]interface{}{"key": "val"},
+								Build: true,
+								Cache: true,
+							},
+						},
+					},
+					{
+						ID:     "other.buildpack.id",
+						Layers: map[string]lifecycle.LayerMetadata{},
+					},
+				},
+			})
+		})
+
+		it("should stop caching when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			cacher.Context = ctx
+
+			if err := cacher.Cache(layersDir, cacheStore); err != context.Canceled {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if _, err := os.Stat(filepath.Join(cacheDir, "committed", "metadata.json")); !os.IsNotExist(err) {
+				t.Fatalf("Expected cache not to be committed: %s\n", err)
+			}
+		})
+
+		it("should reuse layers that are unchanged since the previous cache", func() {
+			h.AssertNil(t, cacher.Cache(layersDir, cacheStore))
+			nextStore, err := lifecycle.NewVolumeCache(cacheDir)
+			h.AssertNil(t, err)
+			stdout.Reset()
+
+			h.AssertNil(t, cacher.Cache(layersDir, nextStore))
+
+			cacheSHA := h.ComputeSHA256ForRelativePath(t, layersDir, filepath.Join(layersDir, "buildpack.id", "cache-layer"), uid, gid)
+			if !strings.Contains(stdout.String(), "reusing cached layer 'buildpack.id/cache-layer' with diffID 'sha256:"+cacheSHA+"'") {
+				t.Fatalf("Unexpected output: %s\n", stdout)
+			}
+			testExists(t, filepath.Join(cacheDir, "committed", cacheSHA+".tar"))
+		})
+
+		it("should remove layers that are no longer cached", func() {
+			h.AssertNil(t, cacher.Cache(layersDir, cacheStore))
+			nextStore, err := lifecycle.NewVolumeCache(cacheDir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.RemoveAll(filepath.Join(layersDir, "buildpack.id", "cache-layer.toml")))
+
+			h.AssertNil(t, cacher.Cache(layersDir, nextStore))
+
+			cacheSHA := h.ComputeSHA256ForRelativePath(t, layersDir, filepath.Join(layersDir, "buildpack.id", "cache-layer"), uid, gid)
+			if _, err := os.Stat(filepath.Join(cacheDir, "committed", cacheSHA+".tar")); !os.IsNotExist(err) {
+				t.Fatalf("Expected stale layer to be removed: %s\n", err)
+			}
+		})
+	})
+}
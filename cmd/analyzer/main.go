@@ -39,6 +39,9 @@ func main() {
 }
 
 func analyzer() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
 	if useHelpers {
 		if err := lifecycle.SetupCredHelpers(repoName); err != nil {
 			return cmd.FailErr(err, "setup credential helpers")
@@ -54,6 +57,7 @@ func analyzer() error {
 		Buildpacks: group.Buildpacks,
 		AppDir:     appDir,
 		LayersDir:  layersDir,
+		Context:    ctx,
 		Out:        log.New(os.Stdout, "", log.LstdFlags),
 		Err:        log.New(os.Stderr, "", log.LstdFlags),
 	}
@@ -84,6 +88,9 @@ func analyzer() error {
 		previousImage,
 	)
 	if err != nil {
+		if err := cmd.FailInterrupted(ctx, "analyze"); err != nil {
+			return err
+		}
 		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
 	}
 
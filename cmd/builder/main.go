@@ -4,8 +4,10 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
 
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/lifecycle/cmd"
@@ -18,6 +20,10 @@ var (
 	layersDir     string
 	appDir        string
 	platformDir   string
+	buildTimeout  time.Duration
+	gracePeriod   time.Duration
+	stackID       string
+	stackMixins   string
 )
 
 func init() {
@@ -27,6 +33,10 @@ func init() {
 	cmd.FlagLayersDir(&layersDir)
 	cmd.FlagAppDir(&appDir)
 	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagBuildTimeout(&buildTimeout)
+	cmd.FlagGracePeriod(&gracePeriod)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
 }
 
 func main() {
@@ -38,11 +48,14 @@ func main() {
 }
 
 func build() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
 	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
 	if err != nil {
 		return cmd.FailErr(err, "read buildpack directory")
 	}
-	group, err := buildpacks.ReadGroup(groupPath)
+	group, err := buildpacks.ReadGroup(groupPath, lifecycle.NewStack(stackID, stackMixins))
 	if err != nil {
 		return cmd.FailErr(err, "read buildpack group")
 	}
@@ -52,12 +65,7 @@ func build() error {
 		return cmd.FailErr(err, "parse build plan")
 	}
 
-	env := &lifecycle.Env{
-		Getenv:  os.Getenv,
-		Setenv:  os.Setenv,
-		Environ: os.Environ,
-		Map:     lifecycle.POSIXBuildEnv,
-	}
+	env := lifecycle.NewMemoryEnv(os.Environ(), lifecycle.POSIXBuildEnv)
 	builder := &lifecycle.Builder{
 		PlatformDir: platformDir,
 		LayersDir:   layersDir,
@@ -65,12 +73,21 @@ func build() error {
 		Env:         env,
 		Buildpacks:  group.Buildpacks,
 		Plan:        plan,
+		Context:     ctx,
+		Timeout:     buildTimeout,
+		GracePeriod: gracePeriod,
 		Out:         os.Stdout,
 		Err:         os.Stderr,
 	}
 
 	metadata, err := builder.Build()
-	if err != nil {
+	switch errors.Cause(err).(type) {
+	case nil:
+	case *lifecycle.TimeoutError:
+		return cmd.FailErrCode(err, cmd.CodeTimeout)
+	case *lifecycle.InterruptError:
+		return cmd.FailErrCode(err, cmd.CodeInterrupted)
+	default:
 		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
 	}
 
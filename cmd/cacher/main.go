@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+var (
+	cacheDir      string
+	cacheImageRef string
+	layersDir     string
+	groupPath     string
+	useDaemon     bool
+	useHelpers    bool
+	uid           int
+	gid           int
+)
+
+func init() {
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagCacheImage(&cacheImageRef)
+	cmd.FlagLayersDir(&layersDir)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+	}
+	cmd.Exit(cache())
+}
+
+func cache() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	var group lifecycle.BuildpackGroup
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		return cmd.FailErr(err, "read group")
+	}
+
+	if useHelpers && cacheImageRef != "" {
+		if err := lifecycle.SetupCredHelpers(cacheImageRef); err != nil {
+			return cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+	cacheStore, err := lifecycle.NewCache(cacheDir, cacheImageRef, useDaemon)
+	if err != nil {
+		return cmd.FailErr(err, "create cache")
+	}
+
+	artifactsDir, err := ioutil.TempDir("", "lifecycle.cacher.layer")
+	if err != nil {
+		return cmd.FailErr(err, "create temp directory")
+	}
+	defer os.RemoveAll(artifactsDir)
+	cacher := &lifecycle.Cacher{
+		Buildpacks:   group.Buildpacks,
+		ArtifactsDir: artifactsDir,
+		Context:      ctx,
+		Out:          log.New(os.Stdout, "", log.LstdFlags),
+		Err:          log.New(os.Stderr, "", log.LstdFlags),
+		UID:          uid,
+		GID:          gid,
+	}
+
+	if err := cacher.Cache(layersDir, cacheStore); err != nil {
+		if err := cmd.FailInterrupted(ctx, "cache"); err != nil {
+			return err
+		}
+		return cmd.FailErrCode(err, cmd.CodeFailedUpdate, "cache")
+	}
+	return nil
+}
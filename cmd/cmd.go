@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -17,15 +18,28 @@ const (
 	DefaultOrderPath      = "/buildpacks/order.toml"
 	DefaultGroupPath      = "./group.toml"
 	DefaultPlanPath       = "./plan.toml"
+	DefaultReportPath     = ""
+	DefaultCacheDir       = "/cache"
+	DefaultLauncherPath   = "/lifecycle/launcher"
+	DefaultPlanConflicts  = "override"
+	DefaultDetectOutput   = "grouped"
 	DefaultUseDaemon      = false
 	DefaultUseCredHelpers = false
 
-	EnvRunImage     = "PACK_RUN_IMAGE"
-	EnvUID          = "PACK_USER_ID"
-	EnvGID          = "PACK_GROUP_ID"
-	EnvLayersDir    = "PACK_LAYERS_DIR"
-	EnvAppDir       = "PACK_APP_DIR"
-	EnvRegistryAuth = "PACK_REGISTRY_AUTH"
+	EnvRunImage      = "PACK_RUN_IMAGE"
+	EnvCacheImage    = "PACK_CACHE_IMAGE"
+	EnvUID           = "PACK_USER_ID"
+	EnvGID           = "PACK_GROUP_ID"
+	EnvLayersDir     = "PACK_LAYERS_DIR"
+	EnvAppDir        = "PACK_APP_DIR"
+	EnvRegistryAuth  = "PACK_REGISTRY_AUTH"
+	EnvDetectTimeout = "PACK_DETECT_TIMEOUT"
+	EnvBuildTimeout  = "PACK_BUILD_TIMEOUT"
+	EnvGracePeriod   = "PACK_GRACE_PERIOD"
+	EnvStackID       = "PACK_STACK_ID"
+	EnvStackMixins   = "PACK_STACK_MIXINS"
+	EnvPlanConflicts = "PACK_PLAN_CONFLICTS"
+	EnvDetectOutput  = "PACK_DETECT_OUTPUT"
 )
 
 func FlagLayersDir(dir *string) {
@@ -56,10 +70,50 @@ func FlagPlanPath(path *string) {
 	flag.StringVar(path, "plan", DefaultPlanPath, "path to plan.toml")
 }
 
+func FlagReportPath(path *string) {
+	flag.StringVar(path, "report", DefaultReportPath, "path to write detect report (.json or .toml)")
+}
+
+func FlagPlanConflicts(policy *string) {
+	flag.StringVar(policy, "plan-conflicts", stringEnv(EnvPlanConflicts, DefaultPlanConflicts), "how to resolve conflicting build plan values (override, keep, or strict)")
+}
+
+func FlagDetectOutput(mode *string) {
+	flag.StringVar(mode, "output", stringEnv(EnvDetectOutput, DefaultDetectOutput), "how to print buildpack detect output (stream or grouped)")
+}
+
+func FlagCacheDir(dir *string) {
+	flag.StringVar(dir, "cache-dir", DefaultCacheDir, "path to cache directory")
+}
+
 func FlagRunImage(image *string) {
 	flag.StringVar(image, "image", os.Getenv(EnvRunImage), "reference to run image")
 }
 
+func FlagStackID(id *string) {
+	flag.StringVar(id, "stack", os.Getenv(EnvStackID), "ID of the stack of the build image")
+}
+
+func FlagStackMixins(mixins *string) {
+	flag.StringVar(mixins, "mixins", os.Getenv(EnvStackMixins), "comma-separated mixins provided by the build image")
+}
+
+func FlagCacheImage(image *string) {
+	flag.StringVar(image, "cache-image", os.Getenv(EnvCacheImage), "reference to cache image")
+}
+
+func FlagDetectTimeout(timeout *time.Duration) {
+	flag.DurationVar(timeout, "detect-timeout", durationEnv(EnvDetectTimeout), "timeout for each buildpack's bin/detect (0 for none)")
+}
+
+func FlagBuildTimeout(timeout *time.Duration) {
+	flag.DurationVar(timeout, "build-timeout", durationEnv(EnvBuildTimeout), "timeout for each buildpack's bin/build (0 for none)")
+}
+
+func FlagGracePeriod(grace *time.Duration) {
+	flag.DurationVar(grace, "grace-period", durationEnv(EnvGracePeriod), "time buildpacks have to exit after an interrupt before they are killed (0 for default)")
+}
+
 func FlagUseDaemon(use *bool) {
 	flag.BoolVar(use, "daemon", DefaultUseDaemon, "export to docker daemon")
 }
@@ -85,6 +139,9 @@ const (
 	CodeFailedBuild
 	CodeFailedLaunch
 	CodeFailedUpdate
+	CodeTimeout
+	CodeInterrupted
+	CodeFailedRetrieve
 )
 
 type ErrorFail struct {
@@ -128,6 +185,13 @@ func Exit(err error) {
 	os.Exit(CodeFailed)
 }
 
+func stringEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
 func intEnv(k string) int {
 	v := os.Getenv(k)
 	d, err := strconv.Atoi(v)
@@ -136,3 +200,12 @@ func intEnv(k string) int {
 	}
 	return d
 }
+
+func durationEnv(k string) time.Duration {
+	v := os.Getenv(k)
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0
+	}
+	return d
+}
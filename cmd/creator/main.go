@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/image"
+)
+
+var (
+	repoName      string
+	runImageRef   string
+	buildpacksDir string
+	orderPath     string
+	layersDir     string
+	appDir        string
+	platformDir   string
+	useDaemon     bool
+	useHelpers    bool
+	uid           int
+	gid           int
+	detectTimeout time.Duration
+	buildTimeout  time.Duration
+	gracePeriod   time.Duration
+	stackID       string
+	stackMixins   string
+	planConflicts string
+	detectOutput  string
+)
+
+func init() {
+	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagOrderPath(&orderPath)
+	cmd.FlagLayersDir(&layersDir)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+	cmd.FlagDetectTimeout(&detectTimeout)
+	cmd.FlagBuildTimeout(&buildTimeout)
+	cmd.FlagGracePeriod(&gracePeriod)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
+	cmd.FlagPlanConflicts(&planConflicts)
+	cmd.FlagDetectOutput(&detectOutput)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() > 1 || flag.Arg(0) == "" || runImageRef == "" {
+		args := map[string]interface{}{"narg": flag.NArg(), "runImage": runImageRef, "layersDir": layersDir}
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments", fmt.Sprintf("%+v", args)))
+	}
+	repoName = flag.Arg(0)
+	cmd.Exit(create())
+}
+
+func create() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	outLog := log.New(os.Stdout, "", log.LstdFlags)
+	errLog := log.New(os.Stderr, "", log.LstdFlags)
+
+	if useHelpers {
+		if err := lifecycle.SetupCredHelpers(repoName, runImageRef); err != nil {
+			return cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+
+	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack directory")
+	}
+	order, err := buildpacks.ReadOrder(orderPath, lifecycle.NewStack(stackID, stackMixins))
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack order file")
+	}
+
+	outLog.Println("===> DETECTING")
+	policy, err := lifecycle.ParsePlanConflictPolicy(planConflicts)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse arguments")
+	}
+	output, err := lifecycle.ParseDetectOutputMode(detectOutput)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse arguments")
+	}
+	report := &lifecycle.DetectReport{}
+	planData, group := order.Detect(&lifecycle.DetectConfig{
+		AppDir:        appDir,
+		PlatformDir:   platformDir,
+		Context:       ctx,
+		Timeout:       detectTimeout,
+		GracePeriod:   gracePeriod,
+		PlanConflicts: policy,
+		Output:        output,
+		Report:        report,
+		Out:           outLog,
+		Err:           errLog,
+	})
+	if err := cmd.FailInterrupted(ctx, "detect"); err != nil {
+		return err
+	}
+	if group == nil {
+		if report.TimedOut() {
+			return cmd.FailCode(cmd.CodeTimeout, "detect")
+		}
+		return cmd.FailCode(cmd.CodeFailedDetect, "detect")
+	}
+	var plan lifecycle.Plan
+	if _, err := toml.Decode(string(planData), &plan); err != nil {
+		return cmd.FailErr(err, "parse build plan")
+	}
+
+	factory, err := image.DefaultFactory()
+	if err != nil {
+		return err
+	}
+	var runImage, origImage image.Image
+	if useDaemon {
+		runImage, err = factory.NewLocal(runImageRef, false)
+		if err != nil {
+			return err
+		}
+		origImage, err = factory.NewLocal(repoName, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		runImage, err = factory.NewRemote(runImageRef)
+		if err != nil {
+			return err
+		}
+		origImage, err = factory.NewRemote(repoName)
+		if err != nil {
+			return err
+		}
+	}
+
+	outLog.Println("===> ANALYZING")
+	analyzer := &lifecycle.Analyzer{
+		Buildpacks: group.Buildpacks,
+		AppDir:     appDir,
+		LayersDir:  layersDir,
+		Context:    ctx,
+		Out:        outLog,
+		Err:        errLog,
+	}
+	if err := analyzer.Analyze(origImage); err != nil {
+		if err := cmd.FailInterrupted(ctx, "analyze"); err != nil {
+			return err
+		}
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild, "analyze")
+	}
+
+	outLog.Println("===> BUILDING")
+	env := lifecycle.NewMemoryEnv(os.Environ(), lifecycle.POSIXBuildEnv)
+	builder := &lifecycle.Builder{
+		PlatformDir: platformDir,
+		LayersDir:   layersDir,
+		AppDir:      appDir,
+		Env:         env,
+		Buildpacks:  group.Buildpacks,
+		Plan:        plan,
+		Context:     ctx,
+		Timeout:     buildTimeout,
+		GracePeriod: gracePeriod,
+		Out:         os.Stdout,
+		Err:         os.Stderr,
+	}
+	metadata, err := builder.Build()
+	switch errors.Cause(err).(type) {
+	case nil:
+	case *lifecycle.TimeoutError:
+		return cmd.FailErrCode(err, cmd.CodeTimeout, "build")
+	case *lifecycle.InterruptError:
+		return cmd.FailErrCode(err, cmd.CodeInterrupted, "build")
+	default:
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild, "build")
+	}
+	metadataPath := filepath.Join(layersDir, "config", "metadata.toml")
+	if err := lifecycle.WriteTOML(metadataPath, metadata); err != nil {
+		return cmd.FailErr(err, "write metadata")
+	}
+
+	outLog.Println("===> EXPORTING")
+	artifactsDir, err := ioutil.TempDir("", "lifecycle.exporter.layer")
+	if err != nil {
+		return cmd.FailErr(err, "create temp directory")
+	}
+	defer os.RemoveAll(artifactsDir)
+	exporter := &lifecycle.Exporter{
+		Buildpacks:   group.Buildpacks,
+		Stack:        lifecycle.NewStack(stackID, stackMixins),
+		Out:          outLog,
+		Err:          errLog,
+		UID:          uid,
+		GID:          gid,
+		ArtifactsDir: artifactsDir,
+		Context:      ctx,
+	}
+	if err := exporter.Export(layersDir, appDir, runImage, origImage, cmd.DefaultLauncherPath); err != nil {
+		if err := cmd.FailInterrupted(ctx, "export"); err != nil {
+			return err
+		}
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild, "export")
+	}
+
+	return nil
+}
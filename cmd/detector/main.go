@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"time"
 
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/lifecycle/cmd"
@@ -16,8 +17,16 @@ var (
 	platformDir   string
 	orderPath     string
 
-	groupPath string
-	planPath  string
+	groupPath  string
+	planPath   string
+	reportPath string
+
+	detectTimeout time.Duration
+	gracePeriod   time.Duration
+	stackID       string
+	stackMixins   string
+	planConflicts string
+	detectOutput  string
 )
 
 func init() {
@@ -28,6 +37,14 @@ func init() {
 
 	cmd.FlagGroupPath(&groupPath)
 	cmd.FlagPlanPath(&planPath)
+	cmd.FlagReportPath(&reportPath)
+
+	cmd.FlagDetectTimeout(&detectTimeout)
+	cmd.FlagGracePeriod(&gracePeriod)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
+	cmd.FlagPlanConflicts(&planConflicts)
+	cmd.FlagDetectOutput(&detectOutput)
 }
 
 func main() {
@@ -39,6 +56,9 @@ func main() {
 }
 
 func detect() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
 	errLog := log.New(os.Stderr, "", log.LstdFlags)
 	outLog := log.New(os.Stdout, "", log.LstdFlags)
 
@@ -46,18 +66,44 @@ func detect() error {
 	if err != nil {
 		return cmd.FailErr(err, "read buildpack directory")
 	}
-	order, err := buildpacks.ReadOrder(orderPath)
+	order, err := buildpacks.ReadOrder(orderPath, lifecycle.NewStack(stackID, stackMixins))
 	if err != nil {
 		return cmd.FailErr(err, "read buildpack order file")
 	}
 
+	policy, err := lifecycle.ParsePlanConflictPolicy(planConflicts)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse arguments")
+	}
+	output, err := lifecycle.ParseDetectOutputMode(detectOutput)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse arguments")
+	}
+	report := &lifecycle.DetectReport{}
 	info, group := order.Detect(&lifecycle.DetectConfig{
-		AppDir:      appDir,
-		PlatformDir: platformDir,
-		Out:         outLog,
-		Err:         errLog,
+		AppDir:        appDir,
+		PlatformDir:   platformDir,
+		Context:       ctx,
+		Timeout:       detectTimeout,
+		GracePeriod:   gracePeriod,
+		PlanConflicts: policy,
+		Output:        output,
+		Report:        report,
+		Out:           outLog,
+		Err:           errLog,
 	})
+	if reportPath != "" {
+		if err := report.Write(reportPath); err != nil {
+			return cmd.FailErr(err, "write detect report")
+		}
+	}
+	if err := cmd.FailInterrupted(ctx, "detect"); err != nil {
+		return err
+	}
 	if group == nil {
+		if report.TimedOut() {
+			return cmd.FailCode(cmd.CodeTimeout, "detect")
+		}
 		return cmd.FailCode(cmd.CodeFailedDetect, "detect")
 	}
 
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+var (
+	buildpacksDir string
+	groupPath     string
+	planPath      string
+	layersDir     string
+	appDir        string
+	platformDir   string
+	buildTimeout  time.Duration
+	gracePeriod   time.Duration
+	stackID       string
+	stackMixins   string
+)
+
+func init() {
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagPlanPath(&planPath)
+	cmd.FlagLayersDir(&layersDir)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagBuildTimeout(&buildTimeout)
+	cmd.FlagGracePeriod(&gracePeriod)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+	}
+	cmd.Exit(develop())
+}
+
+func develop() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack directory")
+	}
+	group, err := buildpacks.ReadGroup(groupPath, lifecycle.NewStack(stackID, stackMixins))
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack group")
+	}
+
+	var plan lifecycle.Plan
+	if _, err := toml.DecodeFile(planPath, &plan); err != nil {
+		return cmd.FailErr(err, "parse build plan")
+	}
+
+	env := lifecycle.NewMemoryEnv(os.Environ(), lifecycle.POSIXBuildEnv)
+	developer := &lifecycle.Developer{
+		PlatformDir: platformDir,
+		LayersDir:   layersDir,
+		AppDir:      appDir,
+		Env:         env,
+		Buildpacks:  group.Buildpacks,
+		Plan:        plan,
+		Context:     ctx,
+		Timeout:     buildTimeout,
+		GracePeriod: gracePeriod,
+		Out:         os.Stdout,
+		Err:         os.Stderr,
+	}
+
+	metadata, err := developer.Develop()
+	switch errors.Cause(err).(type) {
+	case nil:
+	case *lifecycle.TimeoutError:
+		return cmd.FailErrCode(err, cmd.CodeTimeout)
+	case *lifecycle.InterruptError:
+		return cmd.FailErrCode(err, cmd.CodeInterrupted)
+	default:
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
+	}
+
+	metadataPath := filepath.Join(layersDir, "config", "metadata.toml")
+	if err := lifecycle.WriteTOML(metadataPath, metadata); err != nil {
+		return cmd.FailErr(err, "write metadata")
+	}
+	return nil
+}
@@ -7,29 +7,29 @@ import (
 	"log"
 	"os"
 
-	"github.com/BurntSushi/toml"
-
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/lifecycle/cmd"
 	"github.com/buildpack/lifecycle/image"
 )
 
 var (
-	repoName    string
-	runImageRef string
-	layersDir   string
-	appDir      string
-	groupPath   string
-	useDaemon   bool
-	useHelpers  bool
-	uid         int
-	gid         int
+	repoName      string
+	runImageRef   string
+	buildpacksDir string
+	layersDir     string
+	appDir        string
+	groupPath     string
+	useDaemon     bool
+	useHelpers    bool
+	uid           int
+	gid           int
+	stackID       string
+	stackMixins   string
 )
 
-const launcherPath = "/lifecycle/launcher"
-
 func init() {
 	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagBuildpacksDir(&buildpacksDir)
 	cmd.FlagLayersDir(&layersDir)
 	cmd.FlagAppDir(&appDir)
 	cmd.FlagGroupPath(&groupPath)
@@ -37,6 +37,8 @@ func init() {
 	cmd.FlagUseCredHelpers(&useHelpers)
 	cmd.FlagUID(&uid)
 	cmd.FlagGID(&gid)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
 }
 
 func main() {
@@ -50,10 +52,17 @@ func main() {
 }
 
 func export() error {
-	var group lifecycle.BuildpackGroup
-	var err error
-	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
-		return cmd.FailErr(err, "read group")
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	stack := lifecycle.NewStack(stackID, stackMixins)
+	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack directory")
+	}
+	group, err := buildpacks.ReadGroup(groupPath, stack)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack group")
 	}
 	if useHelpers {
 		if err := lifecycle.SetupCredHelpers(repoName, runImageRef); err != nil {
@@ -68,11 +77,13 @@ func export() error {
 	defer os.RemoveAll(artifactsDir)
 	exporter := &lifecycle.Exporter{
 		Buildpacks:   group.Buildpacks,
+		Stack:        stack,
 		Out:          log.New(os.Stdout, "", log.LstdFlags),
 		Err:          log.New(os.Stderr, "", log.LstdFlags),
 		UID:          uid,
 		GID:          gid,
 		ArtifactsDir: artifactsDir,
+		Context:      ctx,
 	}
 
 	factory, err := image.DefaultFactory()
@@ -100,7 +111,10 @@ func export() error {
 		}
 	}
 
-	if err := exporter.Export(layersDir, appDir, runImage, origImage, launcherPath); err != nil {
+	if err := exporter.Export(layersDir, appDir, runImage, origImage, cmd.DefaultLauncherPath); err != nil {
+		if err := cmd.FailInterrupted(ctx, "export"); err != nil {
+			return err
+		}
 		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
 	}
 
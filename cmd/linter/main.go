@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+var (
+	buildpacksDir string
+	orderPath     string
+	stackID       string
+	stackMixins   string
+)
+
+func init() {
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagOrderPath(&orderPath)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagStackMixins(&stackMixins)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+	}
+	cmd.Exit(lint())
+}
+
+func lint() error {
+	buildpacks, problems, err := lifecycle.LintBuildpacks(buildpacksDir)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack directory")
+	}
+	problems = append(problems, buildpacks.LintOrder(orderPath, lifecycle.NewStack(stackID, stackMixins))...)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return cmd.FailCode(cmd.CodeFailed, "lint", fmt.Sprintf("found %d problems", len(problems)))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/image"
+)
+
+var (
+	repoNames   []string
+	runImageRef string
+	useDaemon   bool
+	useHelpers  bool
+)
+
+func init() {
+	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagUseCredHelpers(&useHelpers)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || runImageRef == "" {
+		args := map[string]interface{}{"narg": flag.NArg(), "runImage": runImageRef}
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments", fmt.Sprintf("%+v", args)))
+	}
+	repoNames = flag.Args()
+	cmd.Exit(rebase())
+}
+
+func rebase() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	if useHelpers {
+		if err := lifecycle.SetupCredHelpers(append([]string{runImageRef}, repoNames...)...); err != nil {
+			return cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+
+	rebaser := &lifecycle.Rebaser{
+		Context: ctx,
+		Out:     log.New(os.Stdout, "", log.LstdFlags),
+		Err:     log.New(os.Stderr, "", log.LstdFlags),
+	}
+
+	factory, err := image.DefaultFactory()
+	if err != nil {
+		return err
+	}
+	for _, repoName := range repoNames {
+		var workingImage, newBaseImage image.Image
+		if useDaemon {
+			workingImage, err = factory.NewLocal(repoName, false)
+			if err != nil {
+				return err
+			}
+			newBaseImage, err = factory.NewLocal(runImageRef, false)
+			if err != nil {
+				return err
+			}
+		} else {
+			workingImage, err = factory.NewRemote(repoName)
+			if err != nil {
+				return err
+			}
+			newBaseImage, err = factory.NewRemote(runImageRef)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := rebaser.Rebase(workingImage, newBaseImage); err != nil {
+			if err := cmd.FailInterrupted(ctx, "rebase", repoName); err != nil {
+				return err
+			}
+			return cmd.FailErrCode(err, cmd.CodeFailedUpdate, "rebase", repoName)
+		}
+	}
+	return nil
+}
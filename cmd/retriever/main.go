@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+var (
+	cacheDir      string
+	cacheImageRef string
+	layersDir     string
+	groupPath     string
+	useDaemon     bool
+	useHelpers    bool
+)
+
+func init() {
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagCacheImage(&cacheImageRef)
+	cmd.FlagLayersDir(&layersDir)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagUseCredHelpers(&useHelpers)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+	}
+	cmd.Exit(retrieve())
+}
+
+func retrieve() error {
+	ctx, stop := cmd.SignalContext()
+	defer stop()
+
+	var group lifecycle.BuildpackGroup
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		return cmd.FailErr(err, "read group")
+	}
+
+	if useHelpers && cacheImageRef != "" {
+		if err := lifecycle.SetupCredHelpers(cacheImageRef); err != nil {
+			return cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+	cacheStore, err := lifecycle.NewCache(cacheDir, cacheImageRef, useDaemon)
+	if err != nil {
+		return cmd.FailErr(err, "create cache")
+	}
+
+	retriever := &lifecycle.Retriever{
+		Buildpacks: group.Buildpacks,
+		LayersDir:  layersDir,
+		Context:    ctx,
+		Out:        log.New(os.Stdout, "", log.LstdFlags),
+		Err:        log.New(os.Stderr, "", log.LstdFlags),
+	}
+
+	if err := retriever.Retrieve(cacheStore); err != nil {
+		if err := cmd.FailInterrupted(ctx, "retrieve"); err != nil {
+			return err
+		}
+		return cmd.FailErrCode(err, cmd.CodeFailedRetrieve, "retrieve")
+	}
+	return nil
+}
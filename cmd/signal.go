@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+type signalKey struct{}
+
+type receivedSignal struct {
+	mu  sync.Mutex
+	sig os.Signal
+}
+
+// NotifyContext returns a copy of parent that is canceled when the process
+// receives one of sigs. Buildpacks run with the returned context receive the
+// same signal. Calling stop releases the signal handler and cancels the context.
+func NotifyContext(parent context.Context, sigs ...os.Signal) (ctx context.Context, stop func()) {
+	received := &receivedSignal{}
+	ctx, cancel := context.WithCancel(context.WithValue(parent, signalKey{}, received))
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		select {
+		case sig := <-ch:
+			received.mu.Lock()
+			received.sig = sig
+			received.mu.Unlock()
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(ch)
+		cancel()
+	}
+}
+
+// Interrupted returns the signal that canceled ctx, or nil if ctx was not
+// canceled by a signal.
+func Interrupted(ctx context.Context) os.Signal {
+	if ctx == nil {
+		return nil
+	}
+	received, ok := ctx.Value(signalKey{}).(*receivedSignal)
+	if !ok {
+		return nil
+	}
+	received.mu.Lock()
+	defer received.mu.Unlock()
+	return received.sig
+}
+
+// SignalContext returns the context that each command passes to its phase.
+// It is canceled on SIGINT or SIGTERM, so that the phase stops early and the
+// command can clean up and exit from main.
+func SignalContext() (ctx context.Context, stop func()) {
+	return NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// FailInterrupted returns a failure with CodeInterrupted if ctx was canceled
+// by a signal, or nil otherwise.
+func FailInterrupted(ctx context.Context, action ...string) error {
+	sig := Interrupted(ctx)
+	if sig == nil {
+		return nil
+	}
+	return FailErrCode(fmt.Errorf("received %s", sig), CodeInterrupted, action...)
+}
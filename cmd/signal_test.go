@@ -0,0 +1,49 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+func TestSignal(t *testing.T) {
+	spec.Run(t, "Signal", testSignal, spec.Report(report.Terminal{}))
+}
+
+func testSignal(t *testing.T, when spec.G, it spec.S) {
+	when(".NotifyContext", func() {
+		it("should cancel the context and record the signal when one is received", func() {
+			ctx, stop := cmd.NotifyContext(context.Background(), syscall.SIGUSR1)
+			defer stop()
+
+			if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+				t.Fatal("Expected context to be canceled.\n")
+			}
+			if sig := cmd.Interrupted(ctx); sig != syscall.SIGUSR1 {
+				t.Fatalf("Unexpected signal: %v\n", sig)
+			}
+		})
+
+		it("should not record a signal when stopped", func() {
+			ctx, stop := cmd.NotifyContext(context.Background(), syscall.SIGUSR1)
+			stop()
+
+			<-ctx.Done()
+			if sig := cmd.Interrupted(ctx); sig != nil {
+				t.Fatalf("Unexpected signal: %v\n", sig)
+			}
+		})
+	})
+}
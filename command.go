@@ -0,0 +1,107 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+const DefaultGracePeriod = 10 * time.Second
+
+type TimeoutError struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("'%s' timed out after %s", e.Path, e.Timeout)
+}
+
+type InterruptError struct {
+	Path   string
+	Signal os.Signal
+}
+
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("'%s' interrupted by %s", e.Path, e.Signal)
+}
+
+type commandConfig struct {
+	ctx     context.Context
+	timeout time.Duration
+	grace   time.Duration
+}
+
+// runCommand runs cmd in its own process group. If the timeout elapses, the
+// whole process group is killed and a *TimeoutError is returned. If ctx is
+// canceled, the signal that canceled it (or SIGTERM) is forwarded to the
+// process group, which is killed if it has not exited after the grace period.
+func runCommand(cmd *exec.Cmd, c commandConfig) error {
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	if parent.Err() != nil {
+		return interruptError(parent, cmd)
+	}
+	timeout := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		timeout, cancel = context.WithTimeout(timeout, c.timeout)
+		defer cancel()
+	}
+	grace := c.grace
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeout.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return &TimeoutError{Path: cmd.Path, Timeout: c.timeout}
+	case <-parent.Done():
+		err := interruptError(parent, cmd)
+		syscall.Kill(-cmd.Process.Pid, err.Signal.(syscall.Signal))
+		select {
+		case <-done:
+		case <-time.After(grace):
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			<-done
+		}
+		return err
+	}
+}
+
+func interruptError(ctx context.Context, c *exec.Cmd) *InterruptError {
+	sig := cmd.Interrupted(ctx)
+	if _, ok := sig.(syscall.Signal); !ok {
+		sig = syscall.SIGTERM
+	}
+	return &InterruptError{Path: c.Path, Signal: sig}
+}
+
+// checkContext returns an error once ctx is canceled, so that phases without
+// buildpack processes stop between steps when the process is interrupted.
+func checkContext(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
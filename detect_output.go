@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// DetectOutputMode determines how the output of each buildpack's bin/detect is printed.
+type DetectOutputMode string
+
+const (
+	// DetectOutputGrouped prints the output of each buildpack as a block once it exits.
+	DetectOutputGrouped DetectOutputMode = "grouped"
+	// DetectOutputStream prints each line of output as it is written, prefixed with the buildpack ID.
+	DetectOutputStream DetectOutputMode = "stream"
+)
+
+func ParseDetectOutputMode(s string) (DetectOutputMode, error) {
+	switch m := DetectOutputMode(s); m {
+	case DetectOutputGrouped, DetectOutputStream:
+		return m, nil
+	case "":
+		return DetectOutputGrouped, nil
+	}
+	return "", fmt.Errorf("invalid detect output mode '%s': must be one of grouped, stream", s)
+}
+
+// streamLogger returns the logger that streamed output is written to. When
+// groups are detected concurrently, c.Out only records messages for later
+// replay, so output is streamed to the original logger instead.
+func (c *DetectConfig) streamLogger() *log.Logger {
+	if c.stream != nil {
+		return c.stream
+	}
+	return c.Out
+}
+
+func (bp *Buildpack) label() string {
+	if bp.ID != "" {
+		return bp.ID
+	}
+	return bp.Name
+}
+
+// lineWriter logs each complete line written to it with a prefix. It is
+// safe to share between the stdout and stderr of a process.
+type lineWriter struct {
+	mu     sync.Mutex
+	out    *log.Logger
+	prefix string
+	buf    []byte
+}
+
+func newLineWriter(out *log.Logger, prefix string) *lineWriter {
+	return &lineWriter{out: out, prefix: prefix}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.out.Print(w.prefix + string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs any remaining partial line.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.out.Print(w.prefix + string(w.buf))
+		w.buf = nil
+	}
+}
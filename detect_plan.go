@@ -0,0 +1,250 @@
+package lifecycle
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PlanConflictPolicy determines how conflicting values contributed to the
+// build plan by different buildpacks during detection are resolved.
+type PlanConflictPolicy string
+
+const (
+	// PlanConflictOverride keeps the value from the later buildpack.
+	PlanConflictOverride PlanConflictPolicy = "override"
+	// PlanConflictKeep keeps the value from the earlier buildpack.
+	PlanConflictKeep PlanConflictPolicy = "keep"
+	// PlanConflictStrict fails detection for the later buildpack.
+	PlanConflictStrict PlanConflictPolicy = "strict"
+)
+
+func ParsePlanConflictPolicy(s string) (PlanConflictPolicy, error) {
+	switch p := PlanConflictPolicy(s); p {
+	case PlanConflictOverride, PlanConflictKeep, PlanConflictStrict:
+		return p, nil
+	case "":
+		return PlanConflictOverride, nil
+	}
+	return "", fmt.Errorf("invalid plan conflict policy '%s': must be one of override, keep, strict", s)
+}
+
+type PlanConflict struct {
+	Key             string
+	Value, NewValue interface{}
+	Owner, NewOwner string
+}
+
+func (c PlanConflict) String() string {
+	return fmt.Sprintf(
+		"plan key '%s' set to %#v by '%s' conflicts with %#v from '%s'",
+		c.Key, c.Value, c.Owner, c.NewValue, c.NewOwner,
+	)
+}
+
+type PlanConflictError struct {
+	Conflicts []PlanConflict
+}
+
+func (e *PlanConflictError) Error() string {
+	var msgs []string
+	for _, c := range e.Conflicts {
+		msgs = append(msgs, c.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// planMerger recursively merges the plans contributed by each buildpack in a
+// group, remembering which buildpack set each value so that conflicts can be
+// attributed to both buildpacks.
+type planMerger struct {
+	policy   PlanConflictPolicy
+	mu       sync.Mutex
+	owners   map[string]string
+	provided map[string]bool
+}
+
+func newPlanMerger(policy PlanConflictPolicy) *planMerger {
+	if policy == "" {
+		policy = PlanConflictOverride
+	}
+	return &planMerger{policy: policy, owners: map[string]string{}, provided: map[string]bool{}}
+}
+
+type planProvide struct {
+	Name string `toml:"name"`
+}
+
+type planRequire struct {
+	Name     string                 `toml:"name"`
+	Version  string                 `toml:"version"`
+	Metadata map[string]interface{} `toml:"metadata"`
+}
+
+type UnmetRequirementsError struct {
+	Requires []string
+}
+
+func (e *UnmetRequirementsError) Error() string {
+	return fmt.Sprintf("unmet requirements: %s", strings.Join(e.Requires, ", "))
+}
+
+// accept parses the plan contributed by a passing buildpack. Its [[provides]]
+// and [[requires]] are removed from the returned plan, and each requirement is
+// added to the plan as an entry named after it. If a requirement is not
+// provided by the buildpack or a buildpack accepted before it, the buildpack
+// fails detection with an *UnmetRequirementsError and nil is returned.
+func (m *planMerger) accept(l *log.Logger, r *detectResult) map[string]interface{} {
+	plan := map[string]interface{}{}
+	if _, err := toml.Decode(string(r.plan), &plan); err != nil {
+		l.Print("Warning: ", err)
+		return nil
+	}
+	var model struct {
+		Provides []planProvide `toml:"provides"`
+		Requires []planRequire `toml:"requires"`
+	}
+	if _, err := toml.Decode(string(r.plan), &model); err != nil {
+		l.Print("Warning: ", err)
+		return nil
+	}
+	delete(plan, "provides")
+	delete(plan, "requires")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	provides := map[string]bool{}
+	for _, p := range model.Provides {
+		provides[p.Name] = true
+	}
+	var unmet []string
+	for _, req := range model.Requires {
+		if !provides[req.Name] && !m.provided[req.Name] {
+			unmet = append(unmet, req.Name)
+		}
+	}
+	if len(unmet) > 0 {
+		r.code = CodeDetectFail
+		r.err = &UnmetRequirementsError{Requires: unmet}
+		return nil
+	}
+	for _, p := range model.Provides {
+		m.provided[p.Name] = true
+		r.provides = append(r.provides, p.Name)
+	}
+	for _, req := range model.Requires {
+		entry := map[string]interface{}{}
+		for k, v := range req.Metadata {
+			entry[k] = v
+		}
+		if req.Version != "" {
+			entry["version"] = req.Version
+		}
+		if existing, ok := plan[req.Name].(map[string]interface{}); ok {
+			for k, v := range entry {
+				existing[k] = v
+			}
+		} else {
+			plan[req.Name] = entry
+		}
+		r.requires = append(r.requires, req.Name)
+	}
+	return plan
+}
+
+// mergeTOML writes orig to out, merged with add if add is non-nil. Values in
+// add are attributed to the buildpack named name. If the policy is
+// PlanConflictStrict and add conflicts with orig, only orig is written and a
+// *PlanConflictError is returned.
+func (m *planMerger) mergeTOML(l *log.Logger, out io.Writer, orig io.Reader, add map[string]interface{}, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := map[string]interface{}{}
+	if orig != nil {
+		if _, err := toml.DecodeReader(orig, &result); err != nil {
+			l.Print("Warning: ", err)
+		}
+	}
+	var mergeErr error
+	if add != nil {
+		if conflicts := m.conflicts(result, add, "", name); len(conflicts) > 0 && m.policy == PlanConflictStrict {
+			mergeErr = &PlanConflictError{Conflicts: conflicts}
+		} else {
+			for _, c := range conflicts {
+				l.Print("Warning: ", c)
+			}
+			m.merge(result, add, "", name)
+		}
+	}
+	if err := toml.NewEncoder(out).Encode(result); err != nil {
+		l.Print("Warning: ", err)
+	}
+	return mergeErr
+}
+
+func (m *planMerger) conflicts(dst, src map[string]interface{}, prefix, name string) []PlanConflict {
+	var out []PlanConflict
+	for _, k := range sortedKeys(src) {
+		key := prefix + k
+		old, ok := dst[k]
+		if !ok {
+			continue
+		}
+		oldTable, oldIsTable := old.(map[string]interface{})
+		newTable, newIsTable := src[k].(map[string]interface{})
+		if oldIsTable && newIsTable {
+			out = append(out, m.conflicts(oldTable, newTable, key+".", name)...)
+		} else if !reflect.DeepEqual(old, src[k]) {
+			out = append(out, PlanConflict{
+				Key:      key,
+				Value:    old,
+				Owner:    m.owners[key],
+				NewValue: src[k],
+				NewOwner: name,
+			})
+		}
+	}
+	return out
+}
+
+func (m *planMerger) merge(dst, src map[string]interface{}, prefix, name string) {
+	for _, k := range sortedKeys(src) {
+		key := prefix + k
+		oldTable, oldIsTable := dst[k].(map[string]interface{})
+		newTable, newIsTable := src[k].(map[string]interface{})
+		if oldIsTable && newIsTable {
+			m.merge(oldTable, newTable, key+".", name)
+			continue
+		}
+		if _, ok := dst[k]; ok && m.policy == PlanConflictKeep {
+			continue
+		}
+		dst[k] = src[k]
+		m.own(src[k], key, name)
+	}
+}
+
+func (m *planMerger) own(v interface{}, key, name string) {
+	m.owners[key] = name
+	if table, ok := v.(map[string]interface{}); ok {
+		for k, v := range table {
+			m.own(v, key+"."+k, name)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
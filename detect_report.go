@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+type DetectReport struct {
+	Groups []GroupReport `toml:"groups" json:"groups"`
+}
+
+type GroupReport struct {
+	Pass       bool              `toml:"pass" json:"pass"`
+	Buildpacks []BuildpackReport `toml:"buildpacks" json:"buildpacks"`
+}
+
+type BuildpackReport struct {
+	ID       string                 `toml:"id" json:"id"`
+	Version  string                 `toml:"version" json:"version"`
+	Name     string                 `toml:"name" json:"name"`
+	Optional bool                   `toml:"optional" json:"optional"`
+	Result   string                 `toml:"result" json:"result"`
+	Code     int                    `toml:"code" json:"code"`
+	Duration string                 `toml:"duration" json:"duration"`
+	Output   string                 `toml:"output" json:"output"`
+	Plan     map[string]interface{} `toml:"plan,omitempty" json:"plan,omitempty"`
+	Error    string                 `toml:"error,omitempty" json:"error,omitempty"`
+
+	Reason        string                 `toml:"reason,omitempty" json:"reason,omitempty"`
+	Matched       []string               `toml:"matched,omitempty" json:"matched,omitempty"`
+	SuggestedPlan map[string]interface{} `toml:"suggested-plan,omitempty" json:"suggested-plan,omitempty"`
+}
+
+func newBuildpackReport(bp *Buildpack, result string, r detectResult) BuildpackReport {
+	report := BuildpackReport{
+		ID:       bp.ID,
+		Version:  bp.Version,
+		Name:     bp.Name,
+		Optional: bp.Optional,
+		Result:   result,
+		Code:     r.code,
+		Duration: r.duration.String(),
+		Output:   string(r.output),
+	}
+	if r.code == CodeDetectPass && len(r.plan) > 0 {
+		if _, err := toml.Decode(string(r.plan), &report.Plan); err != nil {
+			report.Error = err.Error()
+		}
+	}
+	if r.err != nil {
+		report.Error = r.err.Error()
+	}
+	if r.details != nil {
+		report.Reason = r.details.Reason
+		report.Matched = r.details.Matched
+		report.SuggestedPlan = r.details.Plan
+	}
+	return report
+}
+
+// TimedOut returns true if a required buildpack in one of the reported groups
+// timed out during detection. Groups are only reported once they have been
+// evaluated, and an optional buildpack that times out cannot fail its group.
+func (r *DetectReport) TimedOut() bool {
+	for _, g := range r.Groups {
+		for _, bp := range g.Buildpacks {
+			if bp.Result == "timeout" && !bp.Optional {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Write writes the report as JSON if path has a .json extension, and as TOML otherwise.
+func (r *DetectReport) Write(path string) error {
+	if filepath.Ext(path) != ".json" {
+		return WriteTOML(path, r)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
@@ -0,0 +1,53 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DetectDetails is the optional result that bin/detect may write to the path
+// provided as its third argument, explaining why it passed or failed.
+type DetectDetails struct {
+	Reason  string                 `toml:"reason"`
+	Matched []string               `toml:"matched"`
+	Plan    map[string]interface{} `toml:"plan"`
+}
+
+// readDetectDetails reads the result written by bin/detect at path. It
+// returns nil if the buildpack did not write a result or the result is
+// malformed, in which case a warning is logged.
+func readDetectDetails(l *log.Logger, name, path string) *DetectDetails {
+	var details DetectDetails
+	if _, err := toml.DecodeFile(path, &details); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		l.Printf("Warning: invalid detect result from '%s': %s", name, err)
+		return nil
+	}
+	if details.Reason == "" && len(details.Matched) == 0 && len(details.Plan) == 0 {
+		return nil
+	}
+	return &details
+}
+
+// summary returns the explanation printed after the status of a buildpack
+// in the detect results, or an empty string if there is none.
+func (r *detectResult) summary() string {
+	var parts []string
+	if r.err != nil {
+		parts = append(parts, r.err.Error())
+	} else if r.details != nil && r.details.Reason != "" {
+		parts = append(parts, r.details.Reason)
+	}
+	if r.details != nil && len(r.details.Matched) > 0 {
+		parts = append(parts, "matched: "+strings.Join(r.details.Matched, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(parts, "; "))
+}
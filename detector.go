@@ -2,17 +2,18 @@ package lifecycle
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
-
-	"github.com/BurntSushi/toml"
+	"time"
 )
 
 const (
@@ -22,17 +23,34 @@ const (
 )
 
 type Buildpack struct {
-	ID       string `toml:"id"`
-	Version  string `toml:"version"`
-	Optional bool   `toml:"optional,omitempty"`
-	Name     string `toml:"-"`
-	Dir      string `toml:"-"`
+	ID       string         `toml:"id"`
+	Version  string         `toml:"version"`
+	Optional bool           `toml:"optional,omitempty"`
+	Name     string         `toml:"-"`
+	API      string         `toml:"-"`
+	Stacks   []Stack        `toml:"-"`
+	Order    BuildpackOrder `toml:"-"`
+	Provides []string       `toml:"provides,omitempty"`
+	ClearEnv bool           `toml:"-"`
+	Dir      string         `toml:"-"`
 }
 
 type DetectConfig struct {
 	AppDir      string
 	PlatformDir string
-	Out, Err    *log.Logger
+	Concurrency int
+	Context     context.Context
+	Timeout     time.Duration
+	GracePeriod time.Duration
+	// PlanConflicts determines how conflicting plan values are resolved. It defaults to PlanConflictOverride.
+	PlanConflicts PlanConflictPolicy
+	// Output determines how buildpack output is printed. It defaults to DetectOutputGrouped.
+	Output   DetectOutputMode
+	Report   *DetectReport
+	Out, Err *log.Logger
+
+	memo   *detectMemo
+	stream *log.Logger
 }
 
 func (bp *Buildpack) EscapedID() string {
@@ -44,57 +62,141 @@ func escape(id string) string {
 }
 
 func (bp *Buildpack) Detect(c *DetectConfig, in io.Reader, out io.Writer) int {
+	result := bp.runDetect(c, bp.Dir, in, out)
+	if len(result.output) > 0 && c.Output != DetectOutputStream {
+		c.Out.Printf("======== Output: %s ========\n%s", bp.Name, result.output)
+	}
+	return result.code
+}
+
+// runDetect runs bin/detect with in as its input plan. Results are shared
+// through c.memo with other groups that detect the buildpack with the same
+// key.
+func (bp *Buildpack) runDetect(c *DetectConfig, key string, in io.Reader, out io.Writer) detectResult {
+	result := *c.memo.detect(key, func() *detectResult {
+		start := time.Now()
+		result := bp.detect(c, in)
+		result.duration = time.Since(start)
+		return result
+	})
+	if result.err != nil {
+		c.Err.Print("Error: ", result.err)
+		result.code = CodeDetectError
+		return result
+	}
+	if _, err := out.Write(result.plan); err != nil {
+		c.Err.Print("Error: ", err)
+		result.code = CodeDetectError
+		result.err = err
+	}
+	return result
+}
+
+type detectResult struct {
+	code     int
+	plan     []byte
+	provides []string
+	requires []string
+	output   []byte
+	details  *DetectDetails
+	duration time.Duration
+	err      error
+}
+
+func (bp *Buildpack) detect(c *DetectConfig, in io.Reader) *detectResult {
 	detectPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", "detect"))
 	if err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{err: err}
 	}
 	appDir, err := filepath.Abs(c.AppDir)
 	if err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{err: err}
 	}
 	platformDir, err := filepath.Abs(c.PlatformDir)
 	if err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{err: err}
 	}
 	planDir, err := ioutil.TempDir("", filepath.Base(bp.Dir)+".plan.")
 	if err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{err: err}
 	}
 	defer os.RemoveAll(planDir)
 	planPath := filepath.Join(planDir, "plan.toml")
 	if ioutil.WriteFile(planPath, nil, 0777); err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{err: err}
 	}
 	log := &bytes.Buffer{}
-	defer func() {
-		if log.Len() > 0 {
-			c.Out.Printf("======== Output: %s ========\n%s", bp.Name, log)
-		}
-	}()
-	cmd := exec.Command(detectPath, platformDir, planPath)
+	var output io.Writer = log
+	if c.Output == DetectOutputStream {
+		lw := newLineWriter(c.streamLogger(), "["+bp.label()+"] ")
+		defer lw.Flush()
+		output = io.MultiWriter(log, lw)
+	}
+	args := []string{platformDir, planPath}
+	var resultPath string
+	if !bp.apiVersion().Less(api02) {
+		resultPath = filepath.Join(planDir, "result.toml")
+		args = append(args, resultPath)
+	}
+	cmd := exec.Command(detectPath, args...)
 	cmd.Dir = appDir
+	if cmd.Env, err = bp.processEnv(os.Environ(), platformDir); err != nil {
+		return &detectResult{err: err}
+	}
 	cmd.Stdin = in
-	cmd.Stdout = log
-	cmd.Stderr = log
-	if err := cmd.Run(); err != nil {
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = runCommand(cmd, commandConfig{ctx: c.Context, timeout: c.Timeout, grace: c.GracePeriod})
+	var details *DetectDetails
+	if resultPath != "" {
+		details = readDetectDetails(c.Err, bp.Name, resultPath)
+	}
+	if err != nil {
 		if err, ok := err.(*exec.ExitError); ok {
 			if status, ok := err.Sys().(syscall.WaitStatus); ok {
-				return status.ExitStatus()
+				return &detectResult{code: status.ExitStatus(), output: log.Bytes(), details: details}
 			}
 		}
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return &detectResult{output: log.Bytes(), details: details, err: err}
 	}
-	if err := parsePlan(out, planPath); err != nil {
-		c.Err.Print("Error: ", err)
-		return CodeDetectError
+	plan := &bytes.Buffer{}
+	if err := parsePlan(plan, planPath); err != nil {
+		return &detectResult{output: log.Bytes(), details: details, err: err}
+	}
+	return &detectResult{code: CodeDetectPass, plan: plan.Bytes(), output: log.Bytes(), details: details}
+}
+
+type detectMemo struct {
+	mu      sync.Mutex
+	results map[string]*detectMemoEntry
+}
+
+type detectMemoEntry struct {
+	once   sync.Once
+	result *detectResult
+}
+
+func newDetectMemo() *detectMemo {
+	return &detectMemo{results: map[string]*detectMemoEntry{}}
+}
+
+// detect runs fn at most once for each key, sharing the result with every
+// group that asks for the same detection.
+func (m *detectMemo) detect(key string, fn func() *detectResult) *detectResult {
+	if m == nil {
+		return fn()
+	}
+	m.mu.Lock()
+	entry, ok := m.results[key]
+	if !ok {
+		entry = &detectMemoEntry{}
+		m.results[key] = entry
 	}
-	return CodeDetectPass
+	m.mu.Unlock()
+	entry.once.Do(func() {
+		entry.result = fn()
+	})
+	return entry.result
 }
 
 func parsePlan(out io.Writer, path string) error {
@@ -112,64 +214,117 @@ type BuildpackGroup struct {
 }
 
 func (bg *BuildpackGroup) Detect(c *DetectConfig) (plan []byte, group *BuildpackGroup, ok bool) {
+	plan, group, report := bg.detect(c)
+	if c.Report != nil {
+		c.Report.Groups = append(c.Report.Groups, report)
+	}
+	return plan, group, report.Pass
+}
+
+func (bg *BuildpackGroup) detect(c *DetectConfig) (plan []byte, group *BuildpackGroup, report GroupReport) {
 	group = &BuildpackGroup{}
 	detected := true
 	c.Out.Printf("Trying group of %d...", len(bg.Buildpacks))
-	plan, codes := bg.pDetect(c)
+	plan, results := bg.pDetect(c)
+	required := map[string]bool{}
+	for i, result := range results {
+		if len(result.output) > 0 && c.Output != DetectOutputStream {
+			c.Out.Printf("======== Output: %s ========\n%s", bg.Buildpacks[i].Name, result.output)
+		}
+		for _, name := range result.requires {
+			required[name] = true
+		}
+	}
 	c.Out.Printf("======== Results ========")
-	for i, code := range codes {
+	for i, result := range results {
 		name := bg.Buildpacks[i].Name
 		optional := bg.Buildpacks[i].Optional
-		switch code {
+		var status string
+		switch result.code {
 		case CodeDetectPass:
-			c.Out.Printf("%s: pass", name)
-			group.Buildpacks = append(group.Buildpacks, bg.Buildpacks[i])
+			status = "pass"
+			c.Out.Printf("%s: pass%s", name, result.summary())
+			bp := *bg.Buildpacks[i]
+			for _, p := range result.provides {
+				if required[p] {
+					bp.Provides = append(bp.Provides, p)
+				}
+			}
+			group.Buildpacks = append(group.Buildpacks, &bp)
 		case CodeDetectFail:
 			if optional {
-				c.Out.Printf("%s: skip", name)
+				status = "skip"
 			} else {
-				c.Out.Printf("%s: fail", name)
+				status = "fail"
 			}
+			c.Out.Printf("%s: %s%s", name, status, result.summary())
 			detected = detected && optional
 		default:
-			c.Out.Printf("%s: error (%d)", name, code)
+			switch result.err.(type) {
+			case *TimeoutError:
+				status = "timeout"
+				c.Out.Printf("%s: timeout", name)
+			case *InterruptError:
+				status = "interrupted"
+				c.Out.Printf("%s: interrupted", name)
+			default:
+				status = "error"
+				c.Out.Printf("%s: error (%d)", name, result.code)
+			}
 			detected = detected && optional
 		}
+		report.Buildpacks = append(report.Buildpacks, newBuildpackReport(bg.Buildpacks[i], status, result))
 	}
 	detected = detected && len(group.Buildpacks) > 0
-	return plan, group, detected
+	report.Pass = detected
+	return plan, group, report
 }
 
-func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, codes []int) {
-	codes = make([]int, len(bg.Buildpacks))
+func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, results []detectResult) {
+	results = make([]detectResult, len(bg.Buildpacks))
+	merger := newPlanMerger(c.PlanConflicts)
 	wg := sync.WaitGroup{}
 	defer wg.Wait()
 	wg.Add(len(bg.Buildpacks))
 	var lastIn io.ReadCloser
+	var key string
 	for i := range bg.Buildpacks {
+		// The input plan of a buildpack is determined by the buildpacks
+		// before it, so their directories identify the detection to memoize.
+		key += bg.Buildpacks[i].Dir + "\x00"
 		in, out := io.Pipe()
-		go func(i int, last io.ReadCloser) {
+		go func(i int, key string, last io.ReadCloser) {
 			defer wg.Done()
 			defer out.Close()
+			bp := bg.Buildpacks[i]
 			add := &bytes.Buffer{}
+			var orig *bytes.Buffer
 			if last != nil {
 				defer last.Close()
-				orig := &bytes.Buffer{}
+				orig = &bytes.Buffer{}
 				last := io.TeeReader(last, orig)
-				codes[i] = bg.Buildpacks[i].Detect(c, last, add)
+				results[i] = bp.runDetect(c, key, last, add)
 				io.Copy(ioutil.Discard, last)
-				if codes[i] == CodeDetectPass {
-					mergeTOML(c.Err, out, orig, add)
-				} else {
-					mergeTOML(c.Err, out, orig)
-				}
 			} else {
-				codes[i] = bg.Buildpacks[i].Detect(c, nil, add)
-				if codes[i] == CodeDetectPass {
-					mergeTOML(c.Err, out, add)
-				}
+				results[i] = bp.runDetect(c, key, nil, add)
+			}
+			var origIn io.Reader
+			if orig != nil {
+				origIn = orig
 			}
-		}(i, lastIn)
+			var addPlan map[string]interface{}
+			if results[i].code == CodeDetectPass {
+				addPlan = merger.accept(c.Err, &results[i])
+			}
+			if origIn == nil && addPlan == nil {
+				return
+			}
+			if err := merger.mergeTOML(c.Err, out, origIn, addPlan, bp.Name); err != nil {
+				c.Err.Print("Error: ", err)
+				results[i].code = CodeDetectError
+				results[i].err = err
+			}
+		}(i, key, lastIn)
 		lastIn = in
 	}
 	if lastIn != nil {
@@ -180,33 +335,204 @@ func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, codes []int) {
 			plan = p
 		}
 	}
-	return plan, codes
+	return plan, results
 }
 
-func mergeTOML(l *log.Logger, out io.Writer, in ...io.Reader) {
-	result := map[string]interface{}{}
-	for _, r := range in {
-		var m map[string]interface{}
-		if _, err := toml.DecodeReader(r, &m); err != nil {
-			l.Print("Warning: ", err)
-			continue
-		}
-		for k, v := range m {
-			result[k] = v
-		}
+type BuildpackOrder []BuildpackGroup
+
+type groupResult struct {
+	plan   []byte
+	group  *BuildpackGroup
+	report GroupReport
+	log    *detectLog
+}
+
+func (bo BuildpackOrder) Detect(c *DetectConfig) (plan []byte, group *BuildpackGroup) {
+	bo, err := bo.expand(nil)
+	if err != nil {
+		c.Err.Print("Error: ", err)
+		return nil, nil
 	}
-	if err := toml.NewEncoder(out).Encode(result); err != nil {
-		l.Print("Warning: ", err)
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	memo := c.memo
+	if memo == nil {
+		memo = newDetectMemo()
+	}
+	if c.Output == DetectOutputStream {
+		// Streamed output cannot be held back until a group's turn, so the
+		// groups are detected one at a time.
+		gc := *c
+		gc.memo = memo
+		for i := range bo {
+			if plan, group, ok := bo[i].Detect(&gc); ok {
+				return plan, group
+			}
+		}
+		return nil, nil
 	}
-}
 
-type BuildpackOrder []BuildpackGroup
+	results := make([]chan groupResult, len(bo))
+	for i := range results {
+		results[i] = make(chan groupResult, 1)
+	}
+	// Groups after the first passing group are only detected speculatively,
+	// so they are canceled once it is found. Every group that was started is
+	// waited for, so that no buildpack outlives Detect.
+	parent := c.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	base := *c
+	wg := sync.WaitGroup{}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sem := make(chan struct{}, workers)
+		for i := range bo {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				// Closing the results of the groups that were never started
+				// tells the caller that detection was interrupted.
+				for _, r := range results[i:] {
+					close(r)
+				}
+				return
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				dl := &detectLog{}
+				gc := base
+				gc.Context = ctx
+				gc.memo = memo
+				gc.stream = base.streamLogger()
+				gc.Out = dl.logger(base.Out)
+				gc.Err = dl.logger(base.Err)
+				gc.Report = nil
+				plan, group, report := bo[i].detect(&gc)
+				results[i] <- groupResult{plan: plan, group: group, report: report, log: dl}
+			}(i)
+		}
+	}()
 
-func (bo BuildpackOrder) Detect(c *DetectConfig) (plan []byte, group *BuildpackGroup) {
 	for i := range bo {
-		if plan, group, ok := bo[i].Detect(c); ok {
-			return plan, group
+		result, ok := <-results[i]
+		if !ok {
+			return nil, nil
+		}
+		result.log.replay()
+		if c.Report != nil {
+			c.Report.Groups = append(c.Report.Groups, result.report)
+		}
+		if result.report.Pass {
+			return result.plan, result.group
 		}
 	}
 	return nil, nil
 }
+
+// expand replaces each meta-buildpack (a buildpack with an order) with every
+// group in its order, so that only groups of leaf buildpacks remain. An
+// optional meta-buildpack may also be left out entirely. The IDs of the
+// meta-buildpacks being expanded are tracked in path to detect cycles.
+// Buildpacks from different orders only meet in the expanded groups, so
+// those are checked for layer directory collisions.
+func (bo BuildpackOrder) expand(path []string) (BuildpackOrder, error) {
+	var out BuildpackOrder
+	for _, g := range bo {
+		groups, err := g.expand(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, eg := range groups {
+			if err := checkIDCollisions(eg.Buildpacks); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, groups...)
+	}
+	return out, nil
+}
+
+func (bg *BuildpackGroup) expand(path []string) (BuildpackOrder, error) {
+	groups := BuildpackOrder{{}}
+	for _, bp := range bg.Buildpacks {
+		if len(bp.Order) == 0 {
+			for i := range groups {
+				groups[i].Buildpacks = appendBuildpacks(groups[i].Buildpacks, bp)
+			}
+			continue
+		}
+		bpPath, err := appendPath(path, bp.ID)
+		if err != nil {
+			return nil, err
+		}
+		alts, err := bp.Order.expand(bpPath)
+		if err != nil {
+			return nil, err
+		}
+		if bp.Optional {
+			alts = append(alts, BuildpackGroup{})
+		}
+		var next BuildpackOrder
+		for _, g := range groups {
+			for _, alt := range alts {
+				next = append(next, BuildpackGroup{Buildpacks: appendBuildpacks(g.Buildpacks, alt.Buildpacks...)})
+			}
+		}
+		groups = next
+	}
+	return groups, nil
+}
+
+func appendBuildpacks(l []*Buildpack, bps ...*Buildpack) []*Buildpack {
+	out := make([]*Buildpack, 0, len(l)+len(bps))
+	return append(append(out, l...), bps...)
+}
+
+// detectLog records messages logged while a group is detected concurrently
+// with other groups, so that they can be replayed in order once the outcome
+// of every earlier group is known.
+type detectLog struct {
+	mu      sync.Mutex
+	entries []detectLogEntry
+}
+
+type detectLogEntry struct {
+	logger *log.Logger
+	msg    string
+}
+
+func (dl *detectLog) logger(l *log.Logger) *log.Logger {
+	return log.New(&detectLogWriter{log: dl, logger: l}, "", 0)
+}
+
+func (dl *detectLog) replay() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for _, e := range dl.entries {
+		e.logger.Print(e.msg)
+	}
+}
+
+type detectLogWriter struct {
+	log    *detectLog
+	logger *log.Logger
+}
+
+func (w *detectLogWriter) Write(p []byte) (int, error) {
+	w.log.mu.Lock()
+	defer w.log.mu.Unlock()
+	w.log.entries = append(w.log.entries, detectLogEntry{logger: w.logger, msg: string(p)})
+	return len(p), nil
+}
@@ -2,6 +2,9 @@ package lifecycle_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -9,7 +12,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -123,6 +128,564 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should return the first matching group regardless of concurrency", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+
+			result := list[1]
+			result.Buildpacks = result.Buildpacks[:len(result.Buildpacks)-1]
+			for _, concurrency := range []int{1, 2, len(list)} {
+				config.Concurrency = concurrency
+				outLog.Reset()
+				plan, group := list.Detect(config)
+				if s := cmp.Diff(*group, result); s != "" {
+					t.Fatalf("Unexpected group with concurrency %d:\n%s\n", concurrency, s)
+				}
+				if s := cmp.Diff(string(plan), "[1]\n  1 = true\n\n[2]\n  2 = true\n\n[3]\n  3 = true\n"); s != "" {
+					t.Fatalf("Unexpected plan with concurrency %d:\n%s\n", concurrency, s)
+				}
+				if !strings.HasPrefix(outLog.String(), "Trying group of 4...\n") ||
+					strings.Count(outLog.String(), "Trying group") != 2 {
+					t.Fatalf("Unexpected log with concurrency %d: %s\n", concurrency, outLog)
+				}
+			}
+		})
+
+		it("should run each buildpack's detect once for the same input", func() {
+			counterDir := filepath.Join(tmpDir, "counter")
+			mkdir(t, filepath.Join(counterDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\necho run >> \"$1/runs\"\nexit 100\n",
+				filepath.Join(counterDir, "bin", "detect"),
+			)
+			bp := &lifecycle.Buildpack{Name: "counter", Dir: counterDir}
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp}},
+				{Buildpacks: []*lifecycle.Buildpack{bp}},
+				{Buildpacks: []*lifecycle.Buildpack{bp}},
+			}
+
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if s := cmp.Diff(rdfile(t, filepath.Join(platformDir, "runs")), "run\n"); s != "" {
+				t.Fatalf("Unexpected runs:\n%s\n", s)
+			}
+			if s := cmp.Diff(strings.Count(outLog.String(), "counter: fail"), 3); s != "" {
+				t.Fatalf("Unexpected log:\n%s\n", outLog)
+			}
+		})
+
+		it("should stop groups after the first match", func() {
+			passDir := filepath.Join(tmpDir, "pass")
+			slowDir := filepath.Join(tmpDir, "slow")
+			mkdir(t, filepath.Join(passDir, "bin"), filepath.Join(slowDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\nsleep 0.2\n", filepath.Join(passDir, "bin", "detect"))
+			mkfile(t, "#!/usr/bin/env bash\nexec sleep 10\n", filepath.Join(slowDir, "bin", "detect"))
+			pass := &lifecycle.Buildpack{Name: "pass", Dir: passDir}
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{pass}},
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "slow", Dir: slowDir}}},
+			}
+			config.Concurrency = 2
+
+			start := time.Now()
+			if _, group := order.Detect(config); group == nil || group.Buildpacks[0].Name != "pass" {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Fatalf("Waited for speculative group: %s\n", d)
+			}
+		})
+
+		it("should return when interrupted before every group has started", func() {
+			slowDir := filepath.Join(tmpDir, "slow")
+			mkdir(t, filepath.Join(slowDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\nexec sleep 10\n", filepath.Join(slowDir, "bin", "detect"))
+			var order lifecycle.BuildpackOrder
+			for i := 0; i < 6; i++ {
+				order = append(order, lifecycle.BuildpackGroup{
+					Buildpacks: []*lifecycle.Buildpack{{Name: fmt.Sprintf("slow%d", i), Dir: slowDir}},
+				})
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			config.Context = ctx
+			config.Concurrency = 1
+			time.AfterFunc(200*time.Millisecond, cancel)
+
+			done := make(chan *lifecycle.BuildpackGroup)
+			go func() {
+				_, group := order.Detect(config)
+				done <- group
+			}()
+			select {
+			case group := <-done:
+				if group != nil {
+					t.Fatalf("Unexpected group: %#v\n", group)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Detect did not return after being interrupted.\n")
+			}
+		})
+
+		it("should report every group tried up to the first match", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+			config.Report = &lifecycle.DetectReport{}
+
+			list.Detect(config)
+
+			report := config.Report
+			if len(report.Groups) != 2 {
+				t.Fatalf("Unexpected groups: %#v\n", report.Groups)
+			}
+			if report.Groups[0].Pass || !report.Groups[1].Pass {
+				t.Fatalf("Unexpected group results: %#v\n", report.Groups)
+			}
+			var results []string
+			for _, bp := range report.Groups[1].Buildpacks {
+				results = append(results, bp.Name+": "+bp.Result)
+			}
+			if s := cmp.Diff(results, []string{
+				"buildpack1-name: pass",
+				"buildpack2-name: pass",
+				"buildpack3-name: pass",
+				"buildpack4-name: skip",
+			}); s != "" {
+				t.Fatalf("Unexpected results:\n%s\n", s)
+			}
+			bp := report.Groups[0].Buildpacks[0]
+			if s := cmp.Diff(bp.ID, "buildpack1"); s != "" {
+				t.Fatalf("Unexpected ID:\n%s\n", s)
+			}
+			if s := cmp.Diff(bp.Output, "stdout: 1\nstderr: 1\n"); s != "" {
+				t.Fatalf("Unexpected output:\n%s\n", s)
+			}
+			if s := cmp.Diff(bp.Plan, map[string]interface{}{"1": map[string]interface{}{"1": true}}); s != "" {
+				t.Fatalf("Unexpected plan:\n%s\n", s)
+			}
+			if bp.Duration == "" {
+				t.Fatal("Expected duration")
+			}
+			fail := report.Groups[0].Buildpacks[3]
+			if fail.Result != "fail" || fail.Code != lifecycle.CodeDetectFail || fail.Plan != nil {
+				t.Fatalf("Unexpected failed buildpack: %#v\n", fail)
+			}
+		})
+
+		it("should write the report as JSON or TOML", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "0", filepath.Join(appDir, "last"))
+			config.Report = &lifecycle.DetectReport{}
+			list.Detect(config)
+
+			if err := config.Report.Write(filepath.Join(tmpDir, "report.json")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			var fromJSON lifecycle.DetectReport
+			if err := json.Unmarshal([]byte(rdfile(t, filepath.Join(tmpDir, "report.json"))), &fromJSON); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(fromJSON.Groups) != len(list) {
+				t.Fatalf("Unexpected report: %#v\n", fromJSON)
+			}
+
+			if err := config.Report.Write(filepath.Join(tmpDir, "report.toml")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			var fromTOML lifecycle.DetectReport
+			if _, err := toml.DecodeFile(filepath.Join(tmpDir, "report.toml"), &fromTOML); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(fromTOML.Groups) != len(list) {
+				t.Fatalf("Unexpected report: %#v\n", fromTOML)
+			}
+		})
+
+		it("should report the reasons buildpacks give for their results", func() {
+			goDir := filepath.Join(tmpDir, "go")
+			mkdir(t, filepath.Join(goDir, "bin"))
+			mkfile(t, `#!/usr/bin/env bash
+if [[ -f go.mod ]]; then
+  printf 'reason = "found go.mod"\nmatched = ["go.mod"]\n' > "$3"
+  exit 0
+fi
+printf 'reason = "no go.mod or *.go files"\n[plan.go]\nversion = "1.12"\n' > "$3"
+exit 100
+`, filepath.Join(goDir, "bin", "detect"))
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "go", Dir: goDir, API: "0.2"}}},
+			}
+			config.Report = &lifecycle.DetectReport{}
+
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if !strings.HasSuffix(outLog.String(), "go: fail (no go.mod or *.go files)\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			fail := config.Report.Groups[0].Buildpacks[0]
+			if s := cmp.Diff(fail.Reason, "no go.mod or *.go files"); s != "" {
+				t.Fatalf("Unexpected reason:\n%s\n", s)
+			}
+			if s := cmp.Diff(fail.SuggestedPlan, map[string]interface{}{
+				"go": map[string]interface{}{"version": "1.12"},
+			}); s != "" {
+				t.Fatalf("Unexpected suggested plan:\n%s\n", s)
+			}
+
+			mkfile(t, "module app", filepath.Join(appDir, "go.mod"))
+			outLog.Reset()
+			config.Report = &lifecycle.DetectReport{}
+			if _, group := order.Detect(config); group == nil {
+				t.Fatalf("Expected group: %s\n", outLog)
+			}
+			if !strings.HasSuffix(outLog.String(), "go: pass (found go.mod; matched: go.mod)\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if s := cmp.Diff(config.Report.Groups[0].Buildpacks[0].Matched, []string{"go.mod"}); s != "" {
+				t.Fatalf("Unexpected matched files:\n%s\n", s)
+			}
+		})
+
+		it("should only pass a result path to buildpacks that implement API 0.2 or later", func() {
+			argsDir := filepath.Join(tmpDir, "args")
+			mkdir(t, filepath.Join(argsDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\necho \"args: $#\"\nexit 100\n", filepath.Join(argsDir, "bin", "detect"))
+			for api, expected := range map[string]string{"0.1": "args: 2", "0.2": "args: 3"} {
+				outLog.Reset()
+				order := lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{{Name: "args", Dir: argsDir, API: api}}},
+				}
+				if _, group := order.Detect(config); group != nil {
+					t.Fatalf("Unexpected group: %#v\n", group)
+				}
+				if !strings.Contains(outLog.String(), expected+"\n") {
+					t.Fatalf("Expected '%s' for API %s, got: %s\n", expected, api, outLog)
+				}
+			}
+		})
+
+		it("should expand the order of meta-buildpacks into groups", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+
+			buildpackDir := filepath.Join("testdata", "buildpack")
+			bp := func(name string) *lifecycle.Buildpack {
+				return &lifecycle.Buildpack{Name: name, Dir: buildpackDir}
+			}
+			meta := &lifecycle.Buildpack{ID: "meta", Name: "meta-name", Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp("buildpack1-name"), bp("buildpack2-name"), bp("buildpack3-name"), bp("buildpack4-name")}},
+				{Buildpacks: []*lifecycle.Buildpack{bp("buildpack1-name"), bp("buildpack2-name")}},
+			}}
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{meta, bp("buildpack3-name")}},
+			}
+
+			plan, group := order.Detect(config)
+			if s := cmp.Diff(*group, lifecycle.BuildpackGroup{
+				Buildpacks: []*lifecycle.Buildpack{bp("buildpack1-name"), bp("buildpack2-name"), bp("buildpack3-name")},
+			}); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+			if s := cmp.Diff(string(plan), "[1]\n  1 = true\n\n[2]\n  2 = true\n\n[3]\n  3 = true\n"); s != "" {
+				t.Fatalf("Unexpected plan:\n%s\n", s)
+			}
+			if !strings.HasPrefix(outLog.String(), "Trying group of 5...\n") ||
+				strings.Count(outLog.String(), "Trying group of 3...\n") != 1 {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+		})
+
+		it("should skip an optional meta-buildpack when none of its groups pass", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "1", filepath.Join(appDir, "last"))
+
+			buildpackDir := filepath.Join("testdata", "buildpack")
+			bp1 := &lifecycle.Buildpack{Name: "buildpack1-name", Dir: buildpackDir}
+			bp2 := &lifecycle.Buildpack{Name: "buildpack2-name", Dir: buildpackDir}
+			meta := &lifecycle.Buildpack{ID: "meta", Optional: true, Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp2}},
+			}}
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{meta, bp1}},
+			}
+
+			_, group := order.Detect(config)
+			if s := cmp.Diff(*group, lifecycle.BuildpackGroup{Buildpacks: []*lifecycle.Buildpack{bp1}}); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+		})
+
+		it("should fail when a meta-buildpack includes itself", func() {
+			meta := &lifecycle.Buildpack{ID: "meta"}
+			meta.Order = lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{
+				{ID: "other", Order: lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{meta}}}},
+			}}}
+			order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{meta}}}
+
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if s := cmp.Diff(errLog.String(), "Error: buildpack 'meta' includes itself: meta -> other -> meta\n"); s != "" {
+				t.Fatalf("Unexpected error:\n%s\n", s)
+			}
+		})
+
+		it("should fail when an expanded group has colliding buildpack ids", func() {
+			meta := &lifecycle.Buildpack{ID: "meta", Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{ID: "a/b"}}},
+			}}
+			order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{meta, {ID: "a_b"}}}}
+
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if s := cmp.Diff(errLog.String(), "Error: buildpacks 'a/b' and 'a_b' would both use layers directory 'a_b'\n"); s != "" {
+				t.Fatalf("Unexpected error:\n%s\n", s)
+			}
+		})
+
+		it("should fail when an expanded group has two versions of a buildpack", func() {
+			meta := &lifecycle.Buildpack{ID: "meta", Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{ID: "x", Version: "1"}}},
+			}}
+			order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{meta, {ID: "x", Version: "2"}}}}
+
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if s := cmp.Diff(errLog.String(), "Error: buildpacks 'x@1' and 'x@2' would both use layers directory 'x'\n"); s != "" {
+				t.Fatalf("Unexpected error:\n%s\n", s)
+			}
+		})
+
+		when("buildpacks contribute the same plan entries", func() {
+			var order lifecycle.BuildpackOrder
+
+			it.Before(func() {
+				order = lifecycle.BuildpackOrder{{}}
+				for i, plan := range []string{
+					"[nodejs]\nversion = \"10\"\nname = \"node\"\n[nodejs.metadata]\nsource = \"a\"\n",
+					"[nodejs]\nversion = \"12\"\nname = \"node\"\n[nodejs.metadata]\nbuild = true\n",
+				} {
+					bpDir := filepath.Join(tmpDir, fmt.Sprintf("plan-buildpack%d", i+1))
+					mkdir(t, filepath.Join(bpDir, "bin"))
+					mkfile(t, fmt.Sprintf("#!/usr/bin/env bash\ncat > /dev/null\necho '%s' > \"$2\"\n", plan),
+						filepath.Join(bpDir, "bin", "detect"),
+					)
+					order[0].Buildpacks = append(order[0].Buildpacks, &lifecycle.Buildpack{
+						Name: fmt.Sprintf("plan-buildpack%d", i+1),
+						Dir:  bpDir,
+					})
+				}
+			})
+
+			it("should merge tables recursively and warn about conflicts", func() {
+				plan, group := order.Detect(config)
+				if group == nil {
+					t.Fatal("Expected group.\n")
+				}
+				if s := cmp.Diff(string(plan),
+					"[nodejs]\n  name = \"node\"\n  version = \"12\"\n  [nodejs.metadata]\n    build = true\n    source = \"a\"\n",
+				); s != "" {
+					t.Fatalf("Unexpected plan:\n%s\n", s)
+				}
+				if s := cmp.Diff(errLog.String(),
+					"Warning: plan key 'nodejs.version' set to \"10\" by 'plan-buildpack1' conflicts with \"12\" from 'plan-buildpack2'\n",
+				); s != "" {
+					t.Fatalf("Unexpected warnings:\n%s\n", s)
+				}
+			})
+
+			it("should keep earlier values when configured to", func() {
+				config.PlanConflicts = lifecycle.PlanConflictKeep
+				plan, group := order.Detect(config)
+				if group == nil {
+					t.Fatal("Expected group.\n")
+				}
+				if !strings.Contains(string(plan), "version = \"10\"") {
+					t.Fatalf("Unexpected plan: %s\n", plan)
+				}
+			})
+
+			it("should fail the conflicting buildpack in strict mode", func() {
+				config.PlanConflicts = lifecycle.PlanConflictStrict
+				if _, group := order.Detect(config); group != nil {
+					t.Fatalf("Unexpected group: %#v\n", group)
+				}
+				if !strings.HasSuffix(outLog.String(), "plan-buildpack1: pass\nplan-buildpack2: error (1)\n") {
+					t.Fatalf("Unexpected log: %s\n", outLog)
+				}
+				if !strings.Contains(errLog.String(), "Error: plan key 'nodejs.version' set to \"10\" by 'plan-buildpack1' conflicts with \"12\" from 'plan-buildpack2'") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+		})
+
+		when("buildpacks provide and require plan entries", func() {
+			mkBuildpack := func(name, plan string) *lifecycle.Buildpack {
+				bpDir := filepath.Join(tmpDir, name)
+				mkdir(t, filepath.Join(bpDir, "bin"))
+				mkfile(t, fmt.Sprintf("#!/usr/bin/env bash\ncat > /dev/null\necho '%s' > \"$2\"\n", plan),
+					filepath.Join(bpDir, "bin", "detect"),
+				)
+				return &lifecycle.Buildpack{ID: name, Name: name, Dir: bpDir}
+			}
+
+			it("should pass groups whose requirements are provided", func() {
+				provider := mkBuildpack("provider", "[[provides]]\nname = \"node\"\n[[provides]]\nname = \"npm\"\n")
+				consumer := mkBuildpack("consumer", "[[requires]]\nname = \"node\"\nversion = \"10\"\n[requires.metadata]\nbuild = true\n")
+				order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{provider, consumer}}}
+
+				plan, group := order.Detect(config)
+				if group == nil {
+					t.Fatalf("Expected group: %s\n", outLog)
+				}
+				if s := cmp.Diff(group.Buildpacks[0].Provides, []string{"node"}); s != "" {
+					t.Fatalf("Unexpected provides:\n%s\n", s)
+				}
+				if s := cmp.Diff(group.Buildpacks[1].Provides, []string(nil)); s != "" {
+					t.Fatalf("Unexpected provides:\n%s\n", s)
+				}
+				if s := cmp.Diff(string(plan), "[node]\n  build = true\n  version = \"10\"\n"); s != "" {
+					t.Fatalf("Unexpected plan:\n%s\n", s)
+				}
+			})
+
+			it("should fail groups with unmet requirements", func() {
+				provider := mkBuildpack("provider", "[[provides]]\nname = \"node\"\n")
+				consumer := mkBuildpack("consumer", "[[requires]]\nname = \"node\"\n[[requires]]\nname = \"python\"\n")
+				order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{provider, consumer}}}
+
+				if _, group := order.Detect(config); group != nil {
+					t.Fatalf("Unexpected group: %#v\n", group)
+				}
+				if !strings.HasSuffix(outLog.String(), "provider: pass\nconsumer: fail (unmet requirements: python)\n") {
+					t.Fatalf("Unexpected log: %s\n", outLog)
+				}
+			})
+
+			it("should not satisfy requirements with later buildpacks", func() {
+				consumer := mkBuildpack("consumer", "[[requires]]\nname = \"node\"\n")
+				provider := mkBuildpack("provider", "[[provides]]\nname = \"node\"\n")
+				consumer.Optional = true
+				order := lifecycle.BuildpackOrder{{Buildpacks: []*lifecycle.Buildpack{consumer, provider}}}
+
+				_, group := order.Detect(config)
+				if group == nil {
+					t.Fatalf("Expected group: %s\n", outLog)
+				}
+				if s := cmp.Diff(group.Buildpacks, []*lifecycle.Buildpack{provider}); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(outLog.String(), "consumer: skip (unmet requirements: node)\n") {
+					t.Fatalf("Unexpected log: %s\n", outLog)
+				}
+			})
+		})
+
+		it("should stream buildpack output line by line with a prefix", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+			partialDir := filepath.Join(tmpDir, "partial")
+			mkdir(t, filepath.Join(partialDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\nprintf 'line one\\nline two'\nexit 100\n",
+				filepath.Join(partialDir, "bin", "detect"),
+			)
+			list = append(lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{ID: "partial", Dir: partialDir}}},
+			}, list...)
+			config.Output = lifecycle.DetectOutputStream
+			config.Concurrency = len(list)
+
+			if _, group := list.Detect(config); group == nil {
+				t.Fatalf("Expected group: %s\n", outLog)
+			}
+			if strings.Index(outLog.String(), "[buildpack1] stdout: 1\n") < strings.Index(outLog.String(), "partial: fail\n") {
+				t.Fatalf("Expected groups to stream in order: %s\n", outLog)
+			}
+			for _, line := range []string{
+				"[partial] line one\n",
+				"[partial] line two\n",
+				"[buildpack1] stdout: 1\n",
+				"[buildpack1] stderr: 1\n",
+				"[buildpack/3] stdout: 3\n",
+				"[buildpack-4] stderr: 4\n",
+			} {
+				if !strings.Contains(outLog.String(), line) {
+					t.Fatalf("Expected log to contain %q: %s\n", line, outLog)
+				}
+			}
+			if strings.Contains(outLog.String(), "======== Output") {
+				t.Fatalf("Unexpected grouped output: %s\n", outLog)
+			}
+		})
+
+		it("should provide the platform env to buildpacks that do not clear it", func() {
+			envDir := filepath.Join(tmpDir, "env-buildpack")
+			mkdir(t, filepath.Join(envDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\necho \"node = '${BP_NODE_VERSION:-none}'\" > \"$2\"\n",
+				filepath.Join(envDir, "bin", "detect"),
+			)
+			mkfile(t, "10.x", filepath.Join(platformDir, "env", "BP_NODE_VERSION"))
+
+			for clear, expected := range map[bool]string{false: "10.x", true: "none"} {
+				order := lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{{ID: "env", Dir: envDir, ClearEnv: clear}}},
+				}
+				plan, group := order.Detect(config)
+				if group == nil {
+					t.Fatalf("Expected group: %s\n", outLog)
+				}
+				if s := cmp.Diff(string(plan), fmt.Sprintf("node = %q\n", expected)); s != "" {
+					t.Fatalf("Unexpected plan (clear-env = %t):\n%s\n", clear, s)
+				}
+			}
+			if os.Getenv("BP_NODE_VERSION") != "" {
+				t.Fatal("Expected platform env not to be set in the lifecycle process")
+			}
+		})
+
+		it("should kill detection that exceeds the timeout", func() {
+			sleeperDir := filepath.Join(tmpDir, "sleeper")
+			mkdir(t, filepath.Join(sleeperDir, "bin"))
+			mkfile(t, "#!/usr/bin/env bash\nsleep 60 &\nwait\n",
+				filepath.Join(sleeperDir, "bin", "detect"),
+			)
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "sleeper", Dir: sleeperDir}}},
+			}
+			config.Timeout = 100 * time.Millisecond
+			config.Report = &lifecycle.DetectReport{}
+
+			start := time.Now()
+			if _, group := order.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if elapsed := time.Since(start); elapsed > 30*time.Second {
+				t.Fatalf("Detection was not killed after %s\n", elapsed)
+			}
+			if !strings.HasSuffix(outLog.String(), "sleeper: timeout\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if !config.Report.TimedOut() {
+				t.Fatalf("Expected report to record timeout: %#v\n", config.Report)
+			}
+		})
+
+		it("should not report a timeout of an optional buildpack as timed out", func() {
+			report := &lifecycle.DetectReport{Groups: []lifecycle.GroupReport{
+				{Buildpacks: []lifecycle.BuildpackReport{
+					{Name: "sleeper", Optional: true, Result: "timeout"},
+					{Name: "failer", Result: "fail"},
+				}},
+			}}
+			if report.TimedOut() {
+				t.Fatalf("Unexpected timeout: %#v\n", report)
+			}
+		})
+
 		it("should return empty if no groups match", func() {
 			mkfile(t, "1", filepath.Join(appDir, "add"))
 			mkfile(t, "0", filepath.Join(appDir, "last"))
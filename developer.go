@@ -0,0 +1,7 @@
+package lifecycle
+
+type Developer Builder
+
+func (d *Developer) Develop() (*BuildMetadata, error) {
+	return (*Builder)(d).run("develop")
+}
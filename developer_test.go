@@ -0,0 +1,121 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/testmock"
+)
+
+func TestDeveloper(t *testing.T) {
+	spec.Run(t, "Developer", testDeveloper, spec.Report(report.Terminal{}))
+}
+
+func testDeveloper(t *testing.T, when spec.G, it spec.S) {
+	var (
+		developer      *lifecycle.Developer
+		mockCtrl       *gomock.Controller
+		env            *testmock.MockBuildEnv
+		stdout, stderr *bytes.Buffer
+		tmpDir         string
+		platformDir    string
+		appDir         string
+		layersDir      string
+	)
+
+	it.Before(func() {
+		mockCtrl = gomock.NewController(t)
+		env = testmock.NewMockBuildEnv(mockCtrl)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		platformDir = filepath.Join(tmpDir, "platform")
+		layersDir = filepath.Join(tmpDir, "launch")
+		appDir = filepath.Join(layersDir, "app")
+		mkdir(t, layersDir, appDir, filepath.Join(platformDir, "env"))
+
+		buildpackDir := filepath.Join("testdata", "buildpack")
+		developer = &lifecycle.Developer{
+			PlatformDir: platformDir,
+			LayersDir:   layersDir,
+			AppDir:      appDir,
+			Env:         env,
+			Buildpacks: []*lifecycle.Buildpack{
+				{ID: "buildpack1-id", Dir: buildpackDir},
+				{ID: "buildpack2-id", Dir: buildpackDir},
+			},
+			Plan: lifecycle.Plan{
+				"dep1": {"v": "1"},
+				"dep2": {"v": "2"},
+			},
+			Out: io.MultiWriter(stdout, it.Out()),
+			Err: io.MultiWriter(stderr, it.Out()),
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+		mockCtrl.Finish()
+	})
+
+	when("#Develop", func() {
+		it.Before(func() {
+			env.EXPECT().List().Return([]string{"ID=1"})
+			env.EXPECT().List().Return([]string{"ID=2"})
+		})
+
+		it("should run bin/develop for each buildpack", func() {
+			if _, err := developer.Develop(); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if stdout.String() != "DEV-STDOUT1\nDEV-STDOUT2\n" {
+				t.Fatalf("Unexpected: %s", stdout)
+			}
+			if stderr.String() != "DEV-STDERR1\nDEV-STDERR2\n" {
+				t.Fatalf("Unexpected: %s", stderr)
+			}
+			testPlan(t,
+				lifecycle.Plan{
+					"dep1": {"v": "1"},
+					"dep2": {"v": "2"},
+				},
+				filepath.Join(appDir, "dev-plan1.toml"),
+			)
+		})
+
+		it("should return metadata with the processes from launch.toml", func() {
+			metadata, err := developer.Develop()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(metadata, &lifecycle.BuildMetadata{
+				Processes: []lifecycle.Process{
+					{Type: "override-type", Command: "dev-process2-command"},
+					{Type: "process1-type", Command: "dev-process1-command"},
+					{Type: "process2-type", Command: "dev-process2-command"},
+				},
+				Buildpacks: []string{"buildpack1-id", "buildpack2-id"},
+				BOM: lifecycle.Plan{
+					"dep1": {"v": "1"},
+					"dep2": {"v": "2"},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected metadata:\n%s\n", s)
+			}
+		})
+	})
+}
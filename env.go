@@ -1,9 +1,11 @@
 package lifecycle
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -27,7 +29,7 @@ func (p *Env) AddRootDir(baseDir string) error {
 			return err
 		}
 		for _, key := range vars {
-			value := newDir + prefix(p.Getenv(key), os.PathListSeparator)
+			value := newDir + prefix(p.Getenv(key), string(os.PathListSeparator))
 			if err := p.Setenv(key, value); err != nil {
 				return err
 			}
@@ -36,33 +38,63 @@ func (p *Env) AddRootDir(baseDir string) error {
 	return nil
 }
 
-func prefix(s string, prefix byte) string {
+func prefix(s string, prefix string) string {
 	if s == "" {
 		return ""
 	} else {
-		return string(prefix) + s
+		return prefix + s
 	}
 }
 
+// AddEnvDir sets a variable for each file in envDir, named after the file
+// and set to its contents. The file extension selects how an existing value
+// is modified:
+//
+//	NAME.override  replaces the value
+//	NAME.default   sets the value only if it is empty
+//	NAME.prepend   prepends to the value, separated by the contents of NAME.delim
+//	NAME.append    currently behaves like NAME.prepend
+//	NAME           prepends to the value, separated by the path list separator
 func (p *Env) AddEnvDir(envDir string) error {
-	return eachEnvFile(envDir, func(k, v string) error {
-		parts := strings.SplitN(k, ".", 2)
-		name := parts[0]
-		var action string
-		if len(parts) > 1 {
-			action = strings.ToLower(parts[1])
+	delims := map[string]string{}
+	if err := eachEnvFile(envDir, func(k, v string) error {
+		if name, action := envAction(k); action == "delim" {
+			delims[name] = v
 		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return eachEnvFile(envDir, func(k, v string) error {
+		name, action := envAction(k)
 		switch action {
+		case "delim":
+			return nil
 		case "append":
-			return p.Setenv(name, v+p.Getenv(name))
+			return p.Setenv(name, v+prefix(p.Getenv(name), delims[name]))
+		case "prepend":
+			return p.Setenv(name, v+prefix(p.Getenv(name), delims[name]))
+		case "default":
+			if p.Getenv(name) != "" {
+				return nil
+			}
+			return p.Setenv(name, v)
 		case "override":
 			return p.Setenv(name, v)
 		default:
-			return p.Setenv(name, v+prefix(p.Getenv(name), os.PathListSeparator))
+			return p.Setenv(name, v+prefix(p.Getenv(name), string(os.PathListSeparator)))
 		}
 	})
 }
 
+func envAction(filename string) (name, action string) {
+	parts := strings.SplitN(filename, ".", 2)
+	if len(parts) > 1 {
+		return parts[0], strings.ToLower(parts[1])
+	}
+	return parts[0], ""
+}
+
 func eachEnvFile(dir string, fn func(k, v string) error) error {
 	files, err := ioutil.ReadDir(dir)
 	if os.IsNotExist(err) {
@@ -88,3 +120,129 @@ func eachEnvFile(dir string, fn func(k, v string) error) error {
 func (p *Env) List() []string {
 	return p.Environ()
 }
+
+// MemoryEnv is a BuildEnv that keeps its variables in memory instead of in
+// the environment of the lifecycle process. Builds that use a MemoryEnv can
+// be repeated in the same process by restoring a snapshot or using a new
+// scope for each build.
+type MemoryEnv struct {
+	*Env
+	vars map[string]string
+}
+
+// NewMemoryEnv returns a MemoryEnv containing the variables in environ,
+// which is a list of "key=value" pairs such as that returned by os.Environ.
+func NewMemoryEnv(environ []string, m map[string][]string) *MemoryEnv {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	return newMemoryEnv(vars, m)
+}
+
+func newMemoryEnv(vars map[string]string, m map[string][]string) *MemoryEnv {
+	e := &MemoryEnv{vars: vars}
+	e.Env = &Env{
+		Getenv:  e.Getenv,
+		Setenv:  e.Setenv,
+		Environ: func() []string { return e.Snapshot().List() },
+		Map:     m,
+	}
+	return e
+}
+
+func (e *MemoryEnv) Getenv(key string) string {
+	return e.vars[key]
+}
+
+func (e *MemoryEnv) Setenv(key, value string) error {
+	e.vars[key] = value
+	return nil
+}
+
+// Scope returns a copy of the environment. Changes to the copy do not
+// affect e, so it may be used to run a single buildpack.
+func (e *MemoryEnv) Scope() *MemoryEnv {
+	return newMemoryEnv(e.Snapshot(), e.Map)
+}
+
+// Snapshot returns a copy of the current variables.
+func (e *MemoryEnv) Snapshot() EnvSnapshot {
+	s := EnvSnapshot{}
+	for k, v := range e.vars {
+		s[k] = v
+	}
+	return s
+}
+
+// Restore replaces the variables with those in s.
+func (e *MemoryEnv) Restore(s EnvSnapshot) {
+	e.vars = map[string]string{}
+	for k, v := range s {
+		e.vars[k] = v
+	}
+}
+
+// Diff returns the changes made to the variables since s was taken.
+func (e *MemoryEnv) Diff(s EnvSnapshot) []EnvChange {
+	return s.Diff(e.Snapshot())
+}
+
+type EnvSnapshot map[string]string
+
+// List returns the variables as sorted "key=value" pairs.
+func (s EnvSnapshot) List() []string {
+	out := make([]string, 0, len(s))
+	for k, v := range s {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// EnvChange describes a variable that was set, modified or unset.
+// Old is empty if the variable was set, and New is empty if it was unset.
+type EnvChange struct {
+	Key      string
+	Old, New string
+}
+
+func (c EnvChange) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("+%s=%s", c.Key, c.New)
+	case c.New == "":
+		return fmt.Sprintf("-%s=%s", c.Key, c.Old)
+	}
+	return fmt.Sprintf("~%s=%s (was %s)", c.Key, c.New, c.Old)
+}
+
+// Diff returns the changes from s to to, sorted by key.
+func (s EnvSnapshot) Diff(to EnvSnapshot) []EnvChange {
+	var changes []EnvChange
+	for k, v := range to {
+		if old, ok := s[k]; !ok || old != v {
+			changes = append(changes, EnvChange{Key: k, Old: old, New: v})
+		}
+	}
+	for k, v := range s {
+		if _, ok := to[k]; !ok {
+			changes = append(changes, EnvChange{Key: k, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// withEnvDir returns environ with the variables in envDir applied to it,
+// as AddEnvDir would, without modifying the environment of the lifecycle.
+func withEnvDir(environ []string, envDir string) ([]string, error) {
+	env := NewMemoryEnv(environ, nil)
+	if err := env.AddEnvDir(envDir); err != nil {
+		return nil, err
+	}
+	return env.List(), nil
+}
@@ -141,6 +141,31 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should prepend, append and default env vars using delimiters", func() {
+			mkfile(t, "some-value-prepend", filepath.Join(tmpDir, "SOME_VAR_PREPEND.prepend"), filepath.Join(tmpDir, "SOME_VAR_PREPEND_NEW.prepend"))
+			mkfile(t, "some-value-append", filepath.Join(tmpDir, "SOME_VAR_APPEND.append"))
+			mkfile(t, "some-value-default", filepath.Join(tmpDir, "SOME_VAR_DEFAULT.default"), filepath.Join(tmpDir, "SOME_VAR_DEFAULT_NEW.default"))
+			mkfile(t, " ", filepath.Join(tmpDir, "SOME_VAR_PREPEND.delim"), filepath.Join(tmpDir, "SOME_VAR_PREPEND_NEW.delim"))
+			mkfile(t, ",", filepath.Join(tmpDir, "SOME_VAR_APPEND.delim"))
+			result = map[string]string{
+				"SOME_VAR_PREPEND": "some-value-prepend-orig",
+				"SOME_VAR_APPEND":  "some-value-append-orig",
+				"SOME_VAR_DEFAULT": "some-value-default-orig",
+			}
+			if err := env.AddEnvDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(result, map[string]string{
+				"SOME_VAR_PREPEND":     "some-value-prepend some-value-prepend-orig",
+				"SOME_VAR_PREPEND_NEW": "some-value-prepend",
+				"SOME_VAR_APPEND":      "some-value-append,some-value-append-orig",
+				"SOME_VAR_DEFAULT":     "some-value-default-orig",
+				"SOME_VAR_DEFAULT_NEW": "some-value-default",
+			}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
 		it("should return an error when setenv fails", func() {
 			retErr = errors.New("some error")
 			mkfile(t, "some-value", filepath.Join(tmpDir, "SOME_VAR"))
@@ -149,4 +174,64 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 	})
+
+	when("MemoryEnv", func() {
+		var memEnv *lifecycle.MemoryEnv
+
+		it.Before(func() {
+			memEnv = lifecycle.NewMemoryEnv([]string{"PATH=some", "KEEP=some-keep", "INVALID"}, lifecycle.POSIXBuildEnv)
+		})
+
+		it("should apply env dirs without modifying the process env", func() {
+			mkdir(t, filepath.Join(tmpDir, "bin"), filepath.Join(tmpDir, "env"))
+			mkfile(t, "some-value", filepath.Join(tmpDir, "env", "LIFECYCLE_TEST_VAR"))
+			if err := memEnv.AddRootDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := memEnv.AddEnvDir(filepath.Join(tmpDir, "env")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(memEnv.List(), []string{
+				"KEEP=some-keep",
+				"LIFECYCLE_TEST_VAR=some-value",
+				"PATH=" + filepath.Join(tmpDir, "bin") + ":some",
+			}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+			if _, ok := os.LookupEnv("LIFECYCLE_TEST_VAR"); ok {
+				t.Fatal("Expected process env to be unmodified")
+			}
+		})
+
+		it("should restore snapshots and report changes since them", func() {
+			snapshot := memEnv.Snapshot()
+			memEnv.Restore(lifecycle.EnvSnapshot{"PATH": "some", "NEW": "some-new"})
+			memEnv.Setenv("PATH", "other")
+
+			var changes []string
+			for _, c := range memEnv.Diff(snapshot) {
+				changes = append(changes, c.String())
+			}
+			if s := cmp.Diff(changes, []string{
+				"-KEEP=some-keep",
+				"+NEW=some-new",
+				"~PATH=other (was some)",
+			}); s != "" {
+				t.Fatalf("Unexpected changes:\n%s\n", s)
+			}
+
+			memEnv.Restore(snapshot)
+			if changes := memEnv.Diff(snapshot); len(changes) > 0 {
+				t.Fatalf("Unexpected changes: %v\n", changes)
+			}
+		})
+
+		it("should not share changes made in a scope", func() {
+			scope := memEnv.Scope()
+			scope.Setenv("PATH", "other")
+			if memEnv.Getenv("PATH") != "some" || scope.Getenv("PATH") != "other" {
+				t.Fatalf("Unexpected env: %v %v\n", memEnv.List(), scope.List())
+			}
+		})
+	})
 }
@@ -1,6 +1,7 @@
 package lifecycle
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -23,13 +24,18 @@ import (
 
 type Exporter struct {
 	Buildpacks   []*Buildpack
+	Stack        Stack
 	ArtifactsDir string
+	Context      context.Context
 	In           []byte
 	Out, Err     *log.Logger
 	UID, GID     int
 }
 
 func (e *Exporter) Export(layersDir, appDir string, runImage, origImage image.Image, launcher string) error {
+	if err := e.checkRunImage(runImage); err != nil {
+		return err
+	}
 	metadata, err := e.prepareExport(layersDir, appDir, launcher)
 	if err != nil {
 		return errors.Wrapf(err, "prepare export")
@@ -37,6 +43,25 @@ func (e *Exporter) Export(layersDir, appDir string, runImage, origImage image.Im
 	return e.exportImage(layersDir, appDir, launcher, runImage, origImage, metadata)
 }
 
+func (e *Exporter) checkRunImage(runImage image.Image) error {
+	runStack, err := ImageStack(runImage)
+	if err != nil {
+		return errors.Wrap(err, "read run image stack")
+	}
+	if e.Stack.ID != "" && runStack.ID != "" && runStack.ID != e.Stack.ID {
+		return fmt.Errorf(
+			"run image '%s' is built for stack '%s', but the build image is built for stack '%s': choose a run image for stack '%s'",
+			runImage.Name(), runStack.ID, e.Stack.ID, e.Stack.ID,
+		)
+	}
+	for _, bp := range e.Buildpacks {
+		if err := checkStack(bp, runStack); err != nil {
+			return errors.Wrapf(err, "run image '%s'", runImage.Name())
+		}
+	}
+	return nil
+}
+
 func (e *Exporter) prepareExport(layersDir, appDir, launcher string) (*AppImageMetadata, error) {
 	var err error
 	var metadata AppImageMetadata
@@ -237,6 +262,9 @@ func (e *Exporter) exportImage(layersDir, appDir, launcher string, runImage, ori
 		return errors.Wrap(err, "setting cmd")
 	}
 
+	if err := checkContext(e.Context); err != nil {
+		return err
+	}
 	e.Out.Println("writing image")
 	sha, err := appImage.Save()
 	e.Out.Printf("\n*** Image: %s@%s\n", repoName, sha)
@@ -273,8 +301,17 @@ func (e *Exporter) GetMetadata(image image.Image) (AppImageMetadata, error) {
 }
 
 func (e *Exporter) exportTar(sourceDir string) (string, error) {
+	if err := checkContext(e.Context); err != nil {
+		return "", err
+	}
+	return writeLayerTar(e.ArtifactsDir, "", sourceDir, e.UID, e.GID)
+}
+
+// writeLayerTar writes sourceDir to a tar in artifactsDir named for its
+// diffID. Paths in the tar are relative to baseDir, unless it is empty.
+func writeLayerTar(artifactsDir, baseDir, sourceDir string, uid, gid int) (string, error) {
 	hasher := sha256.New()
-	f, err := ioutil.TempFile(e.ArtifactsDir, "tarfile")
+	f, err := ioutil.TempFile(artifactsDir, "tarfile")
 	if err != nil {
 		return "", err
 	}
@@ -282,7 +319,7 @@ func (e *Exporter) exportTar(sourceDir string) (string, error) {
 	w := io.MultiWriter(hasher, f)
 
 	fs := &fs.FS{}
-	err = fs.WriteTarArchive(w, sourceDir, e.UID, e.GID)
+	err = fs.WriteRelativeTarArchive(w, baseDir, sourceDir, uid, gid)
 	if err != nil {
 		return "", err
 	}
@@ -291,7 +328,7 @@ func (e *Exporter) exportTar(sourceDir string) (string, error) {
 	if err := f.Close(); err != nil {
 		return "", err
 	}
-	if err := os.Rename(f.Name(), filepath.Join(e.ArtifactsDir, sha+".tar")); err != nil {
+	if err := os.Rename(f.Name(), filepath.Join(artifactsDir, sha+".tar")); err != nil {
 		return "", err
 	}
 
@@ -514,6 +514,31 @@ func testExporter(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("run image is for a different stack", func() {
+			it.Before(func() {
+				h.RecursiveCopy(t, filepath.Join("testdata", "exporter", "first", "launch"), layersDir)
+				h.AssertNil(t, fakeRunImage.SetLabel("io.buildpacks.stack.id", "other.stack"))
+			})
+
+			it("returns an error", func() {
+				exporter.Stack = lifecycle.Stack{ID: "some.stack"}
+				origImage := h.NewFakeImage(t, "app/original-Image-Name", "original-top-layer-sha", "some-original-run-image-digest")
+
+				err := exporter.Export(layersDir, appDir, fakeRunImage, origImage, launcherPath)
+				h.AssertError(t, err, "run image 'runImageName' is built for stack 'other.stack', but the build image is built for stack 'some.stack'")
+				h.AssertEq(t, fakeRunImage.IsSaved(), false)
+			})
+
+			it("returns an error when a buildpack requires mixins missing from the run image", func() {
+				h.AssertNil(t, fakeRunImage.SetLabel("io.buildpacks.stack.mixins", "mixin1"))
+				exporter.Buildpacks[0].Stacks = []lifecycle.Stack{{ID: "other.stack", Mixins: []string{"mixin1", "mixin2"}}}
+				origImage := h.NewFakeImage(t, "app/original-Image-Name", "original-top-layer-sha", "some-original-run-image-digest")
+
+				err := exporter.Export(layersDir, appDir, fakeRunImage, origImage, launcherPath)
+				h.AssertError(t, err, "buildpack 'buildpack.id@' requires mixins [mixin2] that are not provided by stack 'other.stack'")
+			})
+		})
+
 		when("dealing with cached layers", func() {
 			var (
 				layer2sha         string
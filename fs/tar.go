@@ -29,10 +29,31 @@ func (*FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
 }
 
 func (*FS) WriteTarArchive(w io.Writer, srcDir string, uid, gid int) error {
+	return writeTarArchive(w, "", srcDir, uid, gid)
+}
+
+// WriteRelativeTarArchive is like WriteTarArchive, but records paths relative
+// to baseDir so that the archive can be extracted into a different directory.
+func (*FS) WriteRelativeTarArchive(w io.Writer, baseDir, srcDir string, uid, gid int) error {
+	return writeTarArchive(w, baseDir, srcDir, uid, gid)
+}
+
+func writeTarArchive(w io.Writer, baseDir, srcDir string, uid, gid int) error {
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
-	err := writeParentDirectoryHeaders(srcDir, tw, uid, gid)
+	name := func(path string) (string, error) {
+		if baseDir == "" {
+			return path, nil
+		}
+		return filepath.Rel(baseDir, path)
+	}
+
+	relDir, err := name(srcDir)
+	if err != nil {
+		return err
+	}
+	err = writeParentDirectoryHeaders(baseDir, relDir, tw, uid, gid)
 	if err != nil {
 		return err
 	}
@@ -57,7 +78,10 @@ func (*FS) WriteTarArchive(w io.Writer, srcDir string, uid, gid int) error {
 				return err
 			}
 		}
-		header.Name = file
+		header.Name, err = name(file)
+		if err != nil {
+			return err
+		}
 		header.ModTime = time.Time{}
 		header.Uid = uid
 		header.Gid = gid
@@ -81,16 +105,16 @@ func (*FS) WriteTarArchive(w io.Writer, srcDir string, uid, gid int) error {
 	})
 }
 
-func writeParentDirectoryHeaders(tarDir string, tw *tar.Writer, uid int, gid int) error {
+func writeParentDirectoryHeaders(baseDir, tarDir string, tw *tar.Writer, uid int, gid int) error {
 	parent := filepath.Dir(tarDir)
 	if parent == "." || parent == "/" {
 		return nil
 	} else {
-		if err := writeParentDirectoryHeaders(parent, tw, uid, gid); err != nil {
+		if err := writeParentDirectoryHeaders(baseDir, parent, tw, uid, gid); err != nil {
 			return err
 		}
 
-		info, err := os.Stat(parent)
+		info, err := os.Stat(filepath.Join(baseDir, parent))
 		if err != nil {
 			return err
 		}
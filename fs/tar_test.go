@@ -167,6 +167,32 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("a base directory is given", func() {
+			it("writes paths relative to the base directory", func() {
+				baseDir, err := filepath.Abs("testdata")
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, fs.WriteRelativeTarArchive(file, baseDir, filepath.Join(baseDir, "dir-to-tar", "sub-dir"), uid, gid))
+				h.AssertNil(t, file.Close())
+
+				file, err = os.Open(tarFile)
+				h.AssertNil(t, err)
+
+				defer file.Close()
+				tr := tar.NewReader(file)
+
+				header, err := tr.Next()
+				h.AssertNil(t, err)
+				h.AssertEq(t, header.Name, "dir-to-tar")
+				assertDirectory(t, header)
+
+				header, err = tr.Next()
+				h.AssertNil(t, err)
+				h.AssertEq(t, header.Name, "dir-to-tar/sub-dir")
+				assertDirectory(t, header)
+			})
+		})
+
 		it("writes parent directories with the existing filesystem permissions", func() {
 			tmpDir, err := ioutil.TempDir("", "tar-permissions-test")
 			h.AssertNil(t, err)
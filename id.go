@@ -0,0 +1,61 @@
+package lifecycle
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ReservedIDs are the buildpack IDs that would collide with directories in
+// the layers directory that do not belong to buildpacks.
+var ReservedIDs = []string{"config", "app"}
+
+// A buildpack ID is one or more segments separated by '/'. Each segment
+// starts with a letter or digit, followed by letters, digits, '.', '-' or '_'.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)*$`)
+
+type InvalidIDError struct {
+	ID     string
+	Reason string
+}
+
+func (e *InvalidIDError) Error() string {
+	return fmt.Sprintf("buildpack id '%s' is %s", e.ID, e.Reason)
+}
+
+// ValidateID returns an *InvalidIDError if id is malformed or its layers
+// directory is reserved.
+func ValidateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return &InvalidIDError{ID: id, Reason: "invalid: segments separated by '/' must start with a letter or digit and contain only letters, digits, '.', '-' and '_'"}
+	}
+	for _, r := range ReservedIDs {
+		if escape(id) == r {
+			return &InvalidIDError{ID: id, Reason: "reserved"}
+		}
+	}
+	return nil
+}
+
+// checkIDCollisions returns an error if two buildpacks in group would use
+// the same layers directory, whether they share an ID or their IDs escape to
+// the same directory.
+func checkIDCollisions(group []*Buildpack) error {
+	seen := map[string]*Buildpack{}
+	for _, bp := range group {
+		if bp.ID == "" {
+			// Only hand-built groups have entries without an ID; buildpacks
+			// read from disk always have one.
+			continue
+		}
+		dir := bp.EscapedID()
+		if prev, ok := seen[dir]; ok {
+			a, b := prev.ID, bp.ID
+			if a == b {
+				a, b = prev.ID+"@"+prev.Version, bp.ID+"@"+bp.Version
+			}
+			return fmt.Errorf("buildpacks '%s' and '%s' would both use layers directory '%s'", a, b, dir)
+		}
+		seen[dir] = bp
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package image
 
+import "io"
+
 type Image interface {
 	Name() string
 	Rename(name string)
@@ -13,6 +15,7 @@ type Image interface {
 	Rebase(string, Image) error
 	AddLayer(path string) error
 	ReuseLayer(sha string) error
+	GetLayer(sha string) (io.ReadCloser, error)
 	TopLayer() (string, error)
 	Save() (string, error)
 	Found() (bool, error)
@@ -18,6 +18,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
@@ -64,6 +65,20 @@ func (f *Factory) NewLocal(repoName string, pull bool) (Image, error) {
 	}, nil
 }
 
+func (f *Factory) NewEmptyLocal(repoName string) Image {
+	inspect := types.ImageInspect{}
+	inspect.Config = &dockercontainer.Config{
+		Labels: map[string]string{},
+	}
+	return &local{
+		Docker:   f.Docker,
+		RepoName: repoName,
+		Inspect:  inspect,
+		FS:       f.FS,
+		prevOnce: &sync.Once{},
+	}
+}
+
 func (l *local) Label(key string) (string, error) {
 	if l.Inspect.Config == nil {
 		return "", fmt.Errorf("failed to get label, image '%s' does not exist", l.RepoName)
@@ -246,6 +261,19 @@ func (l *local) ReuseLayer(sha string) error {
 	return l.AddLayer(filepath.Join(l.prevDir, reuseLayer))
 }
 
+func (l *local) GetLayer(sha string) (io.ReadCloser, error) {
+	if err := l.prevDownload(); err != nil {
+		return nil, err
+	}
+
+	layerID, ok := l.prevMap[sha]
+	if !ok {
+		return nil, fmt.Errorf("SHA %s was not found in %s", sha, l.RepoName)
+	}
+
+	return os.Open(filepath.Join(l.prevDir, layerID))
+}
+
 func (l *local) Save() (string, error) {
 	ctx := context.Background()
 	done := make(chan error)
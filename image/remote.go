@@ -2,6 +2,7 @@ package image
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	v1remote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
@@ -39,6 +41,14 @@ func (f *Factory) NewRemote(repoName string) (Image, error) {
 	}, nil
 }
 
+func (f *Factory) NewEmptyRemote(repoName string) Image {
+	return &remote{
+		RepoName: repoName,
+		Image:    empty.Image,
+		prevOnce: &sync.Once{},
+	}
+}
+
 func newV1Image(repoName string) (v1.Image, error) {
 	ref, auth, err := referenceForRepoName(repoName)
 	if err != nil {
@@ -227,6 +237,18 @@ func (r *remote) ReuseLayer(sha string) error {
 	return err
 }
 
+func (r *remote) GetLayer(sha string) (io.ReadCloser, error) {
+	hash, err := v1.NewHash(sha)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse diff ID '%s'", sha)
+	}
+	layer, err := r.Image.LayerByDiffID(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get layer '%s' from image '%s'", sha, r.RepoName)
+	}
+	return layer.Uncompressed()
+}
+
 func findLayerWithSha(layers []v1.Layer, sha string) (v1.Layer, error) {
 	for _, layer := range layers {
 		diffID, err := layer.DiffID()
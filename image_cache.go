@@ -0,0 +1,87 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle/image"
+)
+
+type ImageCache struct {
+	origImage image.Image
+	newImage  image.Image
+	committed bool
+}
+
+func NewImageCache(origImage, newImage image.Image) *ImageCache {
+	return &ImageCache{
+		origImage: origImage,
+		newImage:  newImage,
+	}
+}
+
+func (c *ImageCache) Name() string {
+	return c.origImage.Name()
+}
+
+func (c *ImageCache) AddLayer(sha, tarPath string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	return c.newImage.AddLayer(tarPath)
+}
+
+func (c *ImageCache) ReuseLayer(sha string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	return c.newImage.ReuseLayer(sha)
+}
+
+func (c *ImageCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	return c.origImage.GetLayer(sha)
+}
+
+func (c *ImageCache) SetMetadata(metadata CacheMetadata) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.Wrap(err, "marshal metadata")
+	}
+	return c.newImage.SetLabel(CacheMetadataLabel, string(data))
+}
+
+func (c *ImageCache) RetrieveMetadata() (CacheMetadata, error) {
+	var metadata CacheMetadata
+	found, err := c.origImage.Found()
+	if err != nil {
+		return metadata, errors.Wrap(err, "looking for cache image")
+	}
+	if !found {
+		return metadata, nil
+	}
+	label, err := c.origImage.Label(CacheMetadataLabel)
+	if err != nil {
+		return metadata, errors.Wrap(err, "getting cache metadata")
+	}
+	if label == "" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return CacheMetadata{}, errors.Wrap(err, "incompatible cache metadata")
+	}
+	return metadata, nil
+}
+
+func (c *ImageCache) Commit() error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.committed = true
+	_, err := c.newImage.Save()
+	return err
+}
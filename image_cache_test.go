@@ -0,0 +1,111 @@
+package lifecycle_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	h "github.com/buildpack/lifecycle/testhelpers"
+	"github.com/buildpack/lifecycle/testmock"
+)
+
+func TestImageCache(t *testing.T) {
+	spec.Run(t, "ImageCache", testImageCache, spec.Report(report.Terminal{}))
+}
+
+func testImageCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockCtrl   *gomock.Controller
+		origImage  *testmock.MockImage
+		newImage   *testmock.MockImage
+		cacheStore *lifecycle.ImageCache
+	)
+
+	it.Before(func() {
+		mockCtrl = gomock.NewController(t)
+		origImage = testmock.NewMockImage(mockCtrl)
+		newImage = testmock.NewMockImage(mockCtrl)
+		cacheStore = lifecycle.NewImageCache(origImage, newImage)
+	})
+
+	it.After(func() {
+		mockCtrl.Finish()
+	})
+
+	when("#RetrieveMetadata", func() {
+		it("should read metadata from the cache image label", func() {
+			origImage.EXPECT().Found().Return(true, nil)
+			origImage.EXPECT().Label("io.buildpacks.lifecycle.cache.metadata").Return(
+				`{"buildpacks": [{"key": "buildpack.id", "layers": {"layer": {"sha": "sha256:some-sha", "cache": true}}}]}`, nil,
+			)
+
+			metadata, err := cacheStore.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, metadata, lifecycle.CacheMetadata{
+				Buildpacks: []lifecycle.BuildpackMetadata{{ID: "buildpack.id", Layers: map[string]lifecycle.LayerMetadata{
+					"layer": {SHA: "sha256:some-sha", Cache: true},
+				}}},
+			})
+		})
+
+		it("should return empty metadata when the cache image does not exist", func() {
+			origImage.EXPECT().Found().Return(false, nil)
+
+			metadata, err := cacheStore.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, metadata, lifecycle.CacheMetadata{})
+		})
+
+		it("should fail when the cache image label is incompatible", func() {
+			origImage.EXPECT().Found().Return(true, nil)
+			origImage.EXPECT().Label("io.buildpacks.lifecycle.cache.metadata").Return("not json", nil)
+
+			_, err := cacheStore.RetrieveMetadata()
+			if err == nil || !strings.Contains(err.Error(), "incompatible cache metadata") {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+		})
+	})
+
+	when("#RetrieveLayer", func() {
+		it("should read the layer from the cache image", func() {
+			origImage.EXPECT().GetLayer("sha256:some-sha").Return(ioutil.NopCloser(strings.NewReader("some-layer")), nil)
+
+			rc, err := cacheStore.RetrieveLayer("sha256:some-sha")
+			h.AssertNil(t, err)
+			contents, err := ioutil.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "some-layer")
+		})
+	})
+
+	when("#Commit", func() {
+		it("should save new and reused layers with metadata to the new image", func() {
+			gomock.InOrder(
+				newImage.EXPECT().AddLayer("/some/layer.tar"),
+				newImage.EXPECT().ReuseLayer("sha256:reused-sha"),
+				newImage.EXPECT().SetLabel("io.buildpacks.lifecycle.cache.metadata", `{"buildpacks":null}`),
+				newImage.EXPECT().Save(),
+			)
+
+			h.AssertNil(t, cacheStore.AddLayer("sha256:some-sha", "/some/layer.tar"))
+			h.AssertNil(t, cacheStore.ReuseLayer("sha256:reused-sha"))
+			h.AssertNil(t, cacheStore.SetMetadata(lifecycle.CacheMetadata{}))
+			h.AssertNil(t, cacheStore.Commit())
+		})
+
+		it("should fail to modify the cache after commit", func() {
+			newImage.EXPECT().Save()
+			h.AssertNil(t, cacheStore.Commit())
+
+			if err := cacheStore.AddLayer("sha256:some-sha", "/some/layer.tar"); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	})
+}
@@ -18,35 +18,107 @@ type Launcher struct {
 	Buildpacks         []string
 	Env                BuildEnv
 	Exec               func(argv0 string, argv []string, envv []string) error
+	Shell              string
 }
 
+// DefaultShell is the shell used to run processes that require one.
+const DefaultShell = "/bin/bash"
+
 func (l *Launcher) Launch(executable, startCommand string) error {
-	if err := l.env(); err != nil {
-		return errors.Wrap(err, "modify env")
-	}
-	startCommand, err := l.processFor(startCommand)
+	process, err := l.processFor(startCommand)
 	if err != nil {
 		return errors.Wrap(err, "determine start command")
 	}
-	launcher, err := l.profileD()
-	if err != nil {
-		return errors.Wrap(err, "determine profile")
+	if err := l.env(process.Type); err != nil {
+		return errors.Wrap(err, "modify env")
 	}
 
-	if err := os.Chdir(l.AppDir); err != nil {
-		return errors.Wrap(err, "change to app directory")
+	var profile []string
+	if !process.Direct {
+		if profile, err = l.profileD(); err != nil {
+			return errors.Wrap(err, "determine profile")
+		}
+	}
+	if err := os.Chdir(l.workingDir(process)); err != nil {
+		return errors.Wrap(err, "change to working directory")
 	}
-	if err := l.Exec("/bin/bash", []string{
-		"bash", "-c",
+
+	if process.Direct {
+		path, err := lookPath(process.Command, l.Env.List())
+		if err != nil {
+			return errors.Wrap(err, "find command")
+		}
+		return l.exec(path, append([]string{process.Command}, process.Args...))
+	}
+	if len(profile) == 0 {
+		if argv, ok := simpleCommand(process); ok {
+			if path, err := lookPath(argv[0], l.Env.List()); err == nil {
+				return l.exec(path, argv)
+			}
+		}
+	}
+
+	shell := l.Shell
+	if shell == "" {
+		shell = DefaultShell
+	}
+	if _, err := os.Stat(shell); err != nil {
+		return errors.Wrapf(err,
+			"run process '%s': it requires the shell '%s', which is not available: use a direct process instead",
+			process.Command, shell,
+		)
+	}
+	launcher := strings.Join(append(profile, "exec "+shellQuote(shell)+` -c "$@"`), "\n")
+	argv := []string{
+		filepath.Base(shell), "-c",
 		launcher, executable,
-		startCommand,
-	}, l.Env.List()); err != nil {
+		process.Command,
+	}
+	if len(process.Args) > 0 {
+		argv[len(argv)-1] += ` "$@"`
+		argv = append(append(argv, executable), process.Args...)
+	}
+	return l.exec(shell, argv)
+}
+
+func (l *Launcher) exec(argv0 string, argv []string) error {
+	if err := l.Exec(argv0, argv, l.Env.List()); err != nil {
 		return errors.Wrap(err, "exec")
 	}
 	return nil
 }
 
-func (l *Launcher) env() error {
+// shellQuote quotes s as a single word for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// simpleCommand returns the argv of process if its command is a list of
+// words that bash would run without interpreting any shell syntax.
+func simpleCommand(process Process) ([]string, bool) {
+	if strings.ContainsAny(process.Command, "\"'`$&|;<>(){}[]*?~#!\\\n") {
+		return nil, false
+	}
+	argv := strings.Fields(process.Command)
+	if len(argv) == 0 || strings.Contains(argv[0], "=") {
+		return nil, false
+	}
+	return append(argv, process.Args...), true
+}
+
+func (l *Launcher) workingDir(process Process) string {
+	if process.WorkingDir == "" {
+		return l.AppDir
+	}
+	if filepath.IsAbs(process.WorkingDir) {
+		return process.WorkingDir
+	}
+	return filepath.Join(l.AppDir, process.WorkingDir)
+}
+
+// env applies the env of each layer. The env.launch/<process-type>
+// directory of each layer is only applied if processType matches.
+func (l *Launcher) env(processType string) error {
 	appInfo, err := os.Stat(l.AppDir)
 	if err != nil {
 		return errors.Wrap(err, "find app directory")
@@ -72,7 +144,21 @@ func (l *Launcher) env() error {
 			if err := l.Env.AddEnvDir(filepath.Join(path, "env")); err != nil {
 				return err
 			}
-			return l.Env.AddEnvDir(filepath.Join(path, "env.launch"))
+			if err := l.Env.AddEnvDir(filepath.Join(path, "env.launch")); err != nil {
+				return err
+			}
+			if processType == "" {
+				return nil
+			}
+			processDir := filepath.Join(path, "env.launch", processType)
+			if fi, err := os.Stat(processDir); os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			} else if !fi.IsDir() {
+				return nil
+			}
+			return l.Env.AddEnvDir(processDir)
 		}); err != nil {
 			return errors.Wrap(err, "add layer env")
 		}
@@ -80,7 +166,9 @@ func (l *Launcher) env() error {
 	})
 }
 
-func (l *Launcher) profileD() (string, error) {
+// profileD returns the commands that source the profile.d scripts of each
+// buildpack and the .profile of the app, if any.
+func (l *Launcher) profileD() ([]string, error) {
 	var out []string
 
 	appendIfFile := func(path string) error {
@@ -98,52 +186,85 @@ func (l *Launcher) profileD() (string, error) {
 	}
 	layersDir, err := filepath.Abs(l.LayersDir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	for _, bp := range l.Buildpacks {
 		scripts, err := filepath.Glob(filepath.Join(layersDir, bp, "*", "profile.d", "*"))
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		for _, script := range scripts {
 			if err := appendIfFile(script); err != nil {
-				return "", err
+				return nil, err
 			}
 		}
 	}
 
 	if err := appendIfFile(filepath.Join(l.AppDir, ".profile")); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	out = append(out, `exec bash -c "$@"`)
-	return strings.Join(out, "\n"), nil
+	return out, nil
 }
 
-func (l *Launcher) processFor(cmd string) (string, error) {
+// processFor returns the process to run for cmd. If cmd is empty or does not
+// name a process type, the default process type or a process without a type
+// that runs cmd is returned.
+func (l *Launcher) processFor(cmd string) (Process, error) {
 	if cmd == "" {
 		if process, ok := l.findProcessType(l.DefaultProcessType); ok {
 			return process, nil
 		}
 
-		return "", fmt.Errorf("process type %s was not found", l.DefaultProcessType)
+		return Process{}, fmt.Errorf("process type %s was not found", l.DefaultProcessType)
 	}
 
 	if process, ok := l.findProcessType(cmd); ok {
 		return process, nil
 	}
 
-	return cmd, nil
+	return Process{Command: cmd}, nil
 }
 
-func (l *Launcher) findProcessType(kind string) (string, bool) {
+func (l *Launcher) findProcessType(kind string) (Process, bool) {
 	for _, p := range l.Processes {
 		if p.Type == kind {
-			return p.Command, true
+			return p, true
+		}
+	}
+
+	return Process{}, false
+}
+
+// lookPath finds the executable named file in the PATH of environ. If file
+// contains a slash, it is used without consulting the PATH.
+func lookPath(file string, environ []string) (string, error) {
+	if strings.Contains(file, "/") {
+		if !isExecutable(file) {
+			return "", fmt.Errorf("executable '%s' not found", file)
 		}
+		return file, nil
 	}
+	var path string
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, "PATH=") {
+			path = strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if p := filepath.Join(dir, file); isExecutable(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("executable '%s' not found in PATH", file)
+}
 
-	return "", false
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir() && fi.Mode()&0111 != 0
 }
 
 func eachDir(dir string, fn func(path string) error) error {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -147,6 +148,139 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("the process has args", func() {
+			it("should provide them to the command", func() {
+				launcher.Processes = []lifecycle.Process{
+					{Type: "web", Command: "some-web-process", Args: []string{"arg1", "arg two"}},
+				}
+				if err := launcher.Launch("/path/to/launcher", ""); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv[4:], []string{
+					`some-web-process "$@"`, "/path/to/launcher", "arg1", "arg two",
+				}); diff != "" {
+					t.Fatalf("syscall.Exec Argv did not match: (-got +want)\n%s\n", diff)
+				}
+			})
+		})
+
+		when("the process is direct", func() {
+			var binary string
+
+			it.Before(func() {
+				binary = filepath.Join(tmpDir, "web-binary")
+				mkdir(t, filepath.Join(tmpDir, "launch", "app", "subdir"))
+				mkfile(t, "", binary)
+				launcher.Processes = []lifecycle.Process{
+					{Type: "web", Command: binary, Args: []string{"arg1", "arg two"}, Direct: true, WorkingDir: "subdir"},
+					{Type: "worker", Command: "some-missing-binary", Direct: true},
+				}
+			})
+
+			it("should exec the command without a shell in its working dir", func() {
+				if err := launcher.Launch("/path/to/launcher", ""); err != nil {
+					t.Fatal(err)
+				}
+				if len(syscallExecArgsColl) != 1 {
+					t.Fatalf("expected syscall.Exec to be called once: actual %v\n", syscallExecArgsColl)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv0, binary); diff != "" {
+					t.Fatalf("syscall.Exec Argv0 did not match: (-got +want)\n%s\n", diff)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv, []string{binary, "arg1", "arg two"}); diff != "" {
+					t.Fatalf("syscall.Exec Argv did not match: (-got +want)\n%s\n", diff)
+				}
+				wd, err := os.Getwd()
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, err := filepath.EvalSymlinks(filepath.Join(tmpDir, "launch", "app", "subdir"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(wd, expected); diff != "" {
+					t.Fatalf("Unexpected working dir: (-got +want)\n%s\n", diff)
+				}
+			})
+
+			it("should return an error if the command is not in the PATH", func() {
+				if err := launcher.Launch("/path/to/launcher", "worker"); err == nil {
+					t.Fatal("expected launch to return an error")
+				} else if !strings.Contains(err.Error(), "executable 'some-missing-binary' not found in PATH") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		})
+
+		when("the process does not require a shell", func() {
+			it.Before(func() {
+				mkfile(t, "", filepath.Join(tmpDir, "launch", "app", "simple"))
+				launcher.Processes = []lifecycle.Process{
+					{Type: "web", Command: "./simple  arg1", Args: []string{"arg two"}},
+					{Type: "shell", Command: "./simple $HOME"},
+				}
+			})
+
+			it("should exec the command without a shell", func() {
+				if err := launcher.Launch("/path/to/launcher", "web"); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv0, "./simple"); diff != "" {
+					t.Fatalf("syscall.Exec Argv0 did not match: (-got +want)\n%s\n", diff)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv, []string{"./simple", "arg1", "arg two"}); diff != "" {
+					t.Fatalf("syscall.Exec Argv did not match: (-got +want)\n%s\n", diff)
+				}
+			})
+
+			it("should use a shell if the command uses shell syntax", func() {
+				if err := launcher.Launch("/path/to/launcher", "shell"); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv0, "/bin/bash"); diff != "" {
+					t.Fatalf("syscall.Exec Argv0 did not match: (-got +want)\n%s\n", diff)
+				}
+			})
+
+			it("should use a shell if the app has a .profile", func() {
+				mkfile(t, "", filepath.Join(tmpDir, "launch", "app", ".profile"))
+				if err := launcher.Launch("/path/to/launcher", "web"); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv0, "/bin/bash"); diff != "" {
+					t.Fatalf("syscall.Exec Argv0 did not match: (-got +want)\n%s\n", diff)
+				}
+			})
+
+			it("should run the process with the configured shell", func() {
+				launcher.Shell = filepath.Join(tmpDir, "sh")
+				mkfile(t, "", launcher.Shell)
+				if err := launcher.Launch("/path/to/launcher", "shell"); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv0, launcher.Shell); diff != "" {
+					t.Fatalf("syscall.Exec Argv0 did not match: (-got +want)\n%s\n", diff)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv[:3], []string{
+					"sh", "-c", "exec '" + launcher.Shell + "' -c \"$@\"",
+				}); diff != "" {
+					t.Fatalf("syscall.Exec Argv did not match: (-got +want)\n%s\n", diff)
+				}
+			})
+
+			it("should fail clearly if a required shell is not available", func() {
+				launcher.Shell = filepath.Join(tmpDir, "missing-shell")
+				if err := launcher.Launch("/path/to/launcher", "shell"); err == nil {
+					t.Fatal("expected launch to return an error")
+				} else if !strings.Contains(err.Error(), "run process './simple $HOME': it requires the shell '"+launcher.Shell+"', which is not available") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+				if len(syscallExecArgsColl) != 0 {
+					t.Fatalf("expected syscall.Exec to not be called: actual %v\n", syscallExecArgsColl)
+				}
+			})
+		})
+
 		when("buildpacks have provided layer directories that could affect the environment", func() {
 			it.Before(func() {
 				mkfile(t, "#!/usr/bin/env bash\necho test1: $TEST_ENV_ONE test2: $TEST_ENV_TWO\n",
@@ -197,6 +331,32 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("buildpacks have provided launch env for specific process types", func() {
+			it.Before(func() {
+				launcher.Buildpacks = []string{"bp.1"}
+				mkdir(t, filepath.Join(tmpDir, "launch", "bp.1", "layer1", "env.launch", "worker"))
+				env.EXPECT().AddRootDir(gomock.Any()).AnyTimes()
+				env.EXPECT().AddEnvDir(filepath.Join(tmpDir, "launch", "bp.1", "layer1", "env")).AnyTimes()
+				env.EXPECT().AddEnvDir(filepath.Join(tmpDir, "launch", "bp.1", "layer1", "env.launch")).AnyTimes()
+			})
+
+			it("should apply the env of the process type being launched", func() {
+				env.EXPECT().AddEnvDir(filepath.Join(tmpDir, "launch", "bp.1", "layer1", "env.launch", "worker"))
+				if err := launcher.Launch("/path/to/launcher", "worker"); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			it("should not apply the env of other process types", func() {
+				if err := launcher.Launch("/path/to/launcher", "web"); err != nil {
+					t.Fatal(err)
+				}
+				if err := launcher.Launch("/path/to/launcher", "some-command"); err != nil {
+					t.Fatal(err)
+				}
+			})
+		})
+
 		when("metadata includes buildpacks that have not contributed layers", func() {
 			it.Before(func() {
 				launcher.Buildpacks = []string{"bp.3"}
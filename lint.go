@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LintProblem is a problem found while validating buildpacks or an order.
+type LintProblem struct {
+	Path    string
+	Message string
+}
+
+func (p LintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// LintBuildpacks validates every buildpack in dir without running it. It
+// returns the buildpacks that could be read, for use with LintOrder, along
+// with every problem found.
+func LintBuildpacks(dir string) (BuildpackMap, []LintProblem, error) {
+	files, err := buildpackTOMLFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	buildpacks := BuildpackMap{}
+	var problems []LintProblem
+	for _, file := range files {
+		report := func(format string, a ...interface{}) {
+			problems = append(problems, LintProblem{Path: file, Message: fmt.Sprintf(format, a...)})
+		}
+		bp, err := readBuildpackTOML(file)
+		if err != nil {
+			report("%s", err)
+			continue
+		}
+		if err := ValidateID(bp.ID); err != nil {
+			report("%s", err)
+		}
+		if bp.Version == "" {
+			report("missing buildpack version")
+		} else if dirVersion := filepath.Base(bp.Dir); bp.Version != dirVersion {
+			report("version '%s' does not match directory '%s'", bp.Version, dirVersion)
+		}
+		if err := checkBuildpackAPI(bp); err != nil {
+			report("%s", err)
+		}
+		if len(bp.Order) == 0 {
+			for _, name := range []string{"detect", "build"} {
+				if msg := checkExecutable(filepath.Join(bp.Dir, "bin", name)); msg != "" {
+					report("bin/%s %s", name, msg)
+				}
+			}
+		}
+		buildpacks[bp.ID+"@"+filepath.Base(bp.Dir)] = bp
+	}
+
+	for _, ref := range sortedRefs(buildpacks) {
+		bp := buildpacks[ref]
+		if len(bp.Order) == 0 {
+			continue
+		}
+		file := filepath.Join(bp.Dir, "buildpack.toml")
+		for i, g := range bp.Order {
+			problems = append(problems, buildpacks.lintGroup(file, fmt.Sprintf("order group %d", i+1), g.Buildpacks, Stack{})...)
+		}
+	}
+	return buildpacks, problems, nil
+}
+
+// LintOrder validates order.toml at orderPath against the buildpacks in m,
+// returning every problem found.
+func (m BuildpackMap) LintOrder(orderPath string, stack Stack) []LintProblem {
+	var order struct {
+		Groups BuildpackOrder `toml:"groups"`
+	}
+	if _, err := toml.DecodeFile(orderPath, &order); err != nil {
+		return []LintProblem{{Path: orderPath, Message: err.Error()}}
+	}
+	if len(order.Groups) == 0 {
+		return []LintProblem{{Path: orderPath, Message: "no groups"}}
+	}
+	var problems []LintProblem
+	for i, g := range order.Groups {
+		problems = append(problems, m.lintGroup(orderPath, fmt.Sprintf("group %d", i+1), g.Buildpacks, stack)...)
+	}
+	return problems
+}
+
+// lintGroup looks up each buildpack in group separately, so that every
+// buildpack that cannot be resolved is reported. Once all of them resolve,
+// the group is expanded to check the groups of leaf buildpacks as well.
+func (m BuildpackMap) lintGroup(path, name string, group []*Buildpack, stack Stack) []LintProblem {
+	if len(group) == 0 {
+		return []LintProblem{{Path: path, Message: name + " is empty"}}
+	}
+	var problems []LintProblem
+	report := func(err error) {
+		problems = append(problems, LintProblem{Path: path, Message: fmt.Sprintf("%s: %s", name, err)})
+	}
+	var resolved []*Buildpack
+	for _, bp := range group {
+		found, err := m.lookup([]*Buildpack{bp}, stack)
+		if err != nil {
+			report(err)
+			continue
+		}
+		resolved = append(resolved, found...)
+	}
+	if err := checkIDCollisions(group); err != nil {
+		report(err)
+	} else if len(problems) == 0 {
+		if _, err := (BuildpackOrder{{Buildpacks: resolved}}).expand(nil); err != nil {
+			report(err)
+		}
+	}
+	return problems
+}
+
+func checkExecutable(path string) string {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "is missing"
+	} else if err != nil {
+		return err.Error()
+	}
+	if fi.IsDir() || fi.Mode()&0111 == 0 {
+		return "is not executable"
+	}
+	return ""
+}
+
+func sortedRefs(m BuildpackMap) []string {
+	refs := make([]string, 0, len(m))
+	for ref := range m {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
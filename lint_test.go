@@ -0,0 +1,141 @@
+package lifecycle_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+)
+
+func TestLint(t *testing.T) {
+	spec.Run(t, "Lint", testLint, spec.Report(report.Terminal{}))
+}
+
+func testLint(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	mkLintBuildpack := func(id, version, dirVersion, nonExecutable string) string {
+		dir := filepath.Join(tmpDir, id, dirVersion)
+		mkdir(t, filepath.Join(dir, "bin"))
+		mkfile(t, fmt.Sprintf(buildpackTOML, id, id+"-name", version), filepath.Join(dir, "buildpack.toml"))
+		mkfile(t, "#!/usr/bin/env bash\n", filepath.Join(dir, "bin", "detect"), filepath.Join(dir, "bin", "build"))
+		if nonExecutable != "" {
+			if err := os.Chmod(filepath.Join(dir, "bin", nonExecutable), 0644); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+		}
+		return filepath.Join(dir, "buildpack.toml")
+	}
+
+	when(".LintBuildpacks", func() {
+		it("should report nothing for valid buildpacks", func() {
+			mkLintBuildpack("buildpack1", "1.0.0", "1.0.0", "")
+			m, problems, err := lifecycle.LintBuildpacks(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(problems) > 0 {
+				t.Fatalf("Unexpected problems: %v\n", problems)
+			}
+			if _, ok := m["buildpack1@1.0.0"]; !ok {
+				t.Fatalf("Missing buildpack: %#v\n", m)
+			}
+		})
+
+		it("should report every problem with every buildpack", func() {
+			detect := mkLintBuildpack("buildpack1", "1.0.0", "1.0.0", "detect")
+			mismatch := mkLintBuildpack("buildpack2", "2.0.0", "2.0.1", "")
+			config := mkLintBuildpack("config", "1.0.0", "1.0.0", "")
+			malformed := filepath.Join(tmpDir, "buildpack3", "1.0.0", "buildpack.toml")
+			mkdir(t, filepath.Dir(malformed))
+			mkfile(t, "[buildpack\n", malformed)
+			missing := filepath.Join(tmpDir, "buildpack4", "1.0.0", "buildpack.toml")
+			mkdir(t, filepath.Dir(missing))
+			mkfile(t, fmt.Sprintf(buildpackTOML, "buildpack4", "buildpack4-name", "1.0.0"), missing)
+
+			_, problems, err := lifecycle.LintBuildpacks(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.String())
+			}
+			if s := cmp.Diff(got, []string{
+				detect + ": bin/detect is not executable",
+				mismatch + ": version '2.0.0' does not match directory '2.0.1'",
+				malformed + ": Near line 1 (last key parsed ''): expected '.' or ']' to end table name, but got '\\n' instead",
+				missing + ": bin/detect is missing",
+				missing + ": bin/build is missing",
+				config + ": buildpack id 'config' is reserved",
+			}); s != "" {
+				t.Fatalf("Unexpected problems:\n%s\n", s)
+			}
+		})
+	})
+
+	when("#LintOrder", func() {
+		it("should report every buildpack that cannot be resolved", func() {
+			mkLintBuildpack("buildpack1", "1.0.0", "1.0.0", "")
+			m, _, err := lifecycle.LintBuildpacks(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			orderPath := filepath.Join(tmpDir, "order.toml")
+			mkfile(t, `
+[[groups]]
+  [[groups.buildpacks]]
+    id = "buildpack1"
+    version = "^1"
+  [[groups.buildpacks]]
+    id = "buildpack2"
+
+[[groups]]
+
+[[groups]]
+  [[groups.buildpacks]]
+    id = "buildpack1"
+    version = "^2"
+`, orderPath)
+
+			var got []string
+			for _, p := range m.LintOrder(orderPath, lifecycle.Stack{}) {
+				got = append(got, p.Message)
+			}
+			if s := cmp.Diff(got, []string{
+				"group 1: buildpack 'buildpack2@latest' missing from image",
+				"group 2 is empty",
+				"group 3: no version of buildpack 'buildpack1' satisfies '^2': available versions are [1.0.0]",
+			}); s != "" {
+				t.Fatalf("Unexpected problems:\n%s\n", s)
+			}
+		})
+
+		it("should report a malformed order", func() {
+			orderPath := filepath.Join(tmpDir, "order.toml")
+			mkfile(t, "[[groups]\n", orderPath)
+			if problems := (lifecycle.BuildpackMap{}).LintOrder(orderPath, lifecycle.Stack{}); len(problems) != 1 {
+				t.Fatalf("Unexpected problems: %v\n", problems)
+			}
+		})
+	})
+}
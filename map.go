@@ -3,6 +3,8 @@ package lifecycle
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
@@ -11,56 +13,215 @@ import (
 type BuildpackMap map[string]*Buildpack
 
 type buildpackTOML struct {
+	API       string `toml:"api"`
 	Buildpack struct {
-		ID      string `toml:"id"`
-		Version string `toml:"version"`
-		Name    string `toml:"name"`
+		ID       string `toml:"id"`
+		Version  string `toml:"version"`
+		Name     string `toml:"name"`
+		ClearEnv bool   `toml:"clear-env"`
 	} `toml:"buildpack"`
+	Stacks []Stack `toml:"stacks"`
+	Order  []struct {
+		Group []*Buildpack `toml:"group"`
+	} `toml:"order"`
 }
 
 func NewBuildpackMap(dir string) (BuildpackMap, error) {
 	buildpacks := BuildpackMap{}
-	glob := filepath.Join(dir, "*", "*", "buildpack.toml")
-	files, err := filepath.Glob(glob)
+	files, err := buildpackTOMLFiles(dir)
 	if err != nil {
 		return nil, err
 	}
 	for _, file := range files {
-		buildpackDir := filepath.Dir(file)
-		_, version := filepath.Split(buildpackDir)
-		var bpTOML buildpackTOML
-		if _, err := toml.DecodeFile(file, &bpTOML); err != nil {
+		bp, err := readBuildpackTOML(file)
+		if err != nil {
 			return nil, err
 		}
-		buildpacks[bpTOML.Buildpack.ID+"@"+version] = &Buildpack{
-			ID:      bpTOML.Buildpack.ID,
-			Version: bpTOML.Buildpack.Version,
-			Name:    bpTOML.Buildpack.Name,
-			Dir:     buildpackDir,
-		}
+		buildpacks[bp.ID+"@"+filepath.Base(bp.Dir)] = bp
 	}
 	return buildpacks, nil
 }
 
-func (m BuildpackMap) lookup(l []*Buildpack) ([]*Buildpack, error) {
+func buildpackTOMLFiles(dir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, "*", "*", "buildpack.toml"))
+}
+
+func readBuildpackTOML(file string) (*Buildpack, error) {
+	var bpTOML buildpackTOML
+	if _, err := toml.DecodeFile(file, &bpTOML); err != nil {
+		return nil, err
+	}
+	if bpTOML.API != "" {
+		if _, err := ParseAPIVersion(bpTOML.API); err != nil {
+			return nil, errors.Wrapf(err, "read '%s'", file)
+		}
+	}
+	var order BuildpackOrder
+	for _, g := range bpTOML.Order {
+		order = append(order, BuildpackGroup{Buildpacks: g.Group})
+	}
+	return &Buildpack{
+		ID:       bpTOML.Buildpack.ID,
+		Version:  bpTOML.Buildpack.Version,
+		Name:     bpTOML.Buildpack.Name,
+		API:      bpTOML.API,
+		Stacks:   bpTOML.Stacks,
+		Order:    order,
+		ClearEnv: bpTOML.Buildpack.ClearEnv,
+		Dir:      filepath.Dir(file),
+	}, nil
+}
+
+func (m BuildpackMap) lookup(l []*Buildpack, stack Stack) ([]*Buildpack, error) {
+	return m.resolve(l, stack, nil)
+}
+
+// resolve looks up each buildpack in l, along with the buildpacks in the
+// order of any meta-buildpack. The refs of the meta-buildpacks being resolved
+// are tracked in path so that cycles are reported instead of followed.
+func (m BuildpackMap) resolve(l []*Buildpack, stack Stack, path []string) ([]*Buildpack, error) {
 	out := make([]*Buildpack, 0, len(l))
 	for _, b := range l {
-		ref := b.ID + "@" + b.Version
-		if b.Version == "" {
-			ref += "latest"
+		if err := ValidateID(b.ID); err != nil {
+			return nil, err
 		}
-		if bp, ok := m[ref]; ok {
-			bp := *bp
-			bp.Optional = b.Optional
-			out = append(out, &bp)
-		} else {
-			return nil, fmt.Errorf("buildpack '%s' missing from image", ref)
+		found, err := m.find(b.ID, b.Version)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBuildpackAPI(found); err != nil {
+			return nil, err
+		}
+		if err := checkStack(found, stack); err != nil {
+			return nil, err
+		}
+		bp := *found
+		bp.Optional = b.Optional
+		bp.Provides = b.Provides
+		if len(bp.Order) > 0 {
+			order, err := m.resolveOrder(&bp, stack, path)
+			if err != nil {
+				return nil, err
+			}
+			bp.Order = order
 		}
+		out = append(out, &bp)
+	}
+	if err := checkIDCollisions(out); err != nil {
+		return nil, err
 	}
 	return out, nil
 }
 
-func (m BuildpackMap) ReadOrder(orderPath string) (BuildpackOrder, error) {
+// find returns the buildpack with the provided ID whose version is exactly
+// version or, failing that, the highest version satisfying version as a
+// semver constraint. An empty version or "latest" selects the highest
+// installed version.
+func (m BuildpackMap) find(id, version string) (*Buildpack, error) {
+	if version == "" {
+		version = "latest"
+	}
+	if bp, ok := m[id+"@"+version]; ok {
+		return bp, nil
+	}
+	versions := m.versions(id)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("buildpack '%s@%s' missing from image", id, version)
+	}
+	available := strings.Join(versions, ", ")
+
+	var constraint semverConstraint
+	if version != "latest" {
+		var err error
+		if constraint, err = parseSemverConstraint(version); err != nil {
+			return nil, fmt.Errorf("buildpack '%s@%s' missing from image: available versions are [%s]", id, version, available)
+		}
+	}
+	// Like a constraint that does not name a pre-release, "latest" prefers
+	// stable versions and only falls back to pre-releases if there are none.
+	highest := func(pre bool) []string {
+		var best []string
+		var bestVersion semver
+		for _, v := range versions {
+			sv, _, err := parseSemver(v)
+			if err != nil || (constraint != nil && !constraint.matches(sv)) || (sv.pre != "" && !pre) {
+				continue
+			}
+			if d := sv.compare(bestVersion); len(best) == 0 || d > 0 {
+				best, bestVersion = []string{v}, sv
+			} else if d == 0 {
+				best = append(best, v)
+			}
+		}
+		return best
+	}
+	best := highest(constraint != nil)
+	if len(best) == 0 && constraint == nil {
+		best = highest(true)
+	}
+	if len(best) == 0 && constraint == nil {
+		if len(versions) == 1 {
+			return m[id+"@"+versions[0]], nil
+		}
+		best = versions
+	}
+	switch len(best) {
+	case 0:
+		return nil, fmt.Errorf("no version of buildpack '%s' satisfies '%s': available versions are [%s]", id, version, available)
+	case 1:
+		return m[id+"@"+best[0]], nil
+	}
+	return nil, fmt.Errorf(
+		"buildpack '%s@%s' is ambiguous: it matches versions [%s]: available versions are [%s]",
+		id, version, strings.Join(best, ", "), available,
+	)
+}
+
+func (m BuildpackMap) versions(id string) []string {
+	var versions []string
+	for ref := range m {
+		i := strings.LastIndex(ref, "@")
+		if i >= 0 && ref[:i] == id {
+			versions = append(versions, ref[i+1:])
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// resolveOrder resolves the order of the meta-buildpack bp. The refs of the
+// meta-buildpacks already being resolved are passed in path.
+func (m BuildpackMap) resolveOrder(bp *Buildpack, stack Stack, path []string) (BuildpackOrder, error) {
+	ref := bp.ID + "@" + bp.Version
+	path, err := appendPath(path, ref)
+	if err != nil {
+		return nil, err
+	}
+	var order BuildpackOrder
+	for _, g := range bp.Order {
+		group, err := m.resolve(g.Buildpacks, stack, path)
+		if err != nil {
+			return nil, err
+		}
+		order = append(order, BuildpackGroup{Buildpacks: group})
+	}
+	return order, nil
+}
+
+// appendPath returns a copy of path with ref added, or an error if ref is
+// already in path because a meta-buildpack includes itself.
+func appendPath(path []string, ref string) ([]string, error) {
+	for i, p := range path {
+		if p == ref {
+			return nil, fmt.Errorf("buildpack '%s' includes itself: %s", ref, strings.Join(append(path[i:], ref), " -> "))
+		}
+	}
+	return append(append([]string{}, path...), ref), nil
+}
+
+// ReadOrder reads order.toml at orderPath and returns an error if any
+// buildpack it refers to is missing or does not support stack.
+func (m BuildpackMap) ReadOrder(orderPath string, stack Stack) (BuildpackOrder, error) {
 	var order struct {
 		Groups BuildpackOrder `toml:"groups"`
 	}
@@ -70,7 +231,7 @@ func (m BuildpackMap) ReadOrder(orderPath string) (BuildpackOrder, error) {
 
 	var groups BuildpackOrder
 	for _, g := range order.Groups {
-		group, err := m.lookup(g.Buildpacks)
+		group, err := m.lookup(g.Buildpacks, stack)
 		if err != nil {
 			return nil, errors.Wrap(err, "lookup buildpacks")
 		}
@@ -90,15 +251,22 @@ func (g *BuildpackGroup) Write(path string) error {
 	return WriteTOML(path, data)
 }
 
-func (m BuildpackMap) ReadGroup(path string) (*BuildpackGroup, error) {
+// ReadGroup reads group.toml at path and returns an error if any
+// buildpack it refers to is missing or does not support stack.
+func (m BuildpackMap) ReadGroup(path string, stack Stack) (*BuildpackGroup, error) {
 	var group BuildpackGroup
 	var err error
 	if _, err := toml.DecodeFile(path, &group); err != nil {
 		return nil, err
 	}
-	group.Buildpacks, err = m.lookup(group.Buildpacks)
+	group.Buildpacks, err = m.lookup(group.Buildpacks, stack)
 	if err != nil {
 		return nil, errors.Wrap(err, "lookup buildpacks")
 	}
+	for _, bp := range group.Buildpacks {
+		if len(bp.Order) > 0 {
+			return nil, fmt.Errorf("buildpack '%s@%s' has an order and cannot be used in a group", bp.ID, bp.Version)
+		}
+	}
 	return &group, nil
 }
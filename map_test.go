@@ -66,6 +66,61 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf("Unexpected map:\n%s\n", s)
 			}
 		})
+
+		it("should read the buildpack API version", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, "api = \"0.2\"\n"+fmt.Sprintf(buildpackTOML, "buildpack1", "buildpack1-name", "version1"),
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(m["buildpack1@version1"].API, "0.2"); s != "" {
+				t.Fatalf("Unexpected API:\n%s\n", s)
+			}
+		})
+
+		it("should read whether the buildpack clears the platform env", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, fmt.Sprintf(buildpackTOML, "buildpack1", "buildpack1-name", "version1")+"clear-env = true\n",
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if !m["buildpack1@version1"].ClearEnv {
+				t.Fatalf("Expected clear-env: %#v\n", m["buildpack1@version1"])
+			}
+		})
+
+		it("should error when the buildpack API version is malformed", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, "api = \"latest\"\n"+fmt.Sprintf(buildpackTOML, "buildpack1", "buildpack1-name", "version1"),
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			if _, err := lifecycle.NewBuildpackMap(tmpDir); err == nil {
+				t.Fatal("Expected error.\n")
+			} else if !strings.Contains(err.Error(), "invalid API version 'latest'") {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
 	})
 
 	when("#ReadOrder", func() {
@@ -92,7 +147,7 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 			mkfile(t, `groups = [{ buildpacks = [{id = "buildpack1", version = "version1.1"}, {id = "buildpack2", optional = true}] }]`,
 				filepath.Join(tmpDir, "order.toml"),
 			)
-			actual, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			actual, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -112,12 +167,383 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				mkfile(t, `groups = [{ buildpacks = [{id = "buildpack1", version = "version1.1"}, {id = "buildpack2", optional = true}] }]`,
 					filepath.Join(tmpDir, "order.toml"),
 				)
-				_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+				_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
 				if err == nil {
 					t.Fatal("expected an error")
 				}
 			})
 		})
+		when("order references an invalid buildpack id", func() {
+			it("returns an error", func() {
+				m := lifecycle.BuildpackMap{
+					"config@latest":  {ID: "config"},
+					"bad//id@latest": {ID: "bad//id"},
+					"-bad@latest":    {ID: "-bad"},
+				}
+				for id, expected := range map[string]string{
+					"config":  "buildpack id 'config' is reserved",
+					"bad//id": "buildpack id 'bad//id' is invalid",
+					"-bad":    "buildpack id '-bad' is invalid",
+				} {
+					mkfile(t, fmt.Sprintf(`groups = [{ buildpacks = [{id = "%s"}] }]`, id),
+						filepath.Join(tmpDir, "order.toml"),
+					)
+					_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
+					if err == nil {
+						t.Fatalf("Expected error for '%s'.\n", id)
+					} else if !strings.Contains(err.Error(), expected) {
+						t.Fatalf("Incorrect error: %s\n", err)
+					}
+				}
+			})
+		})
+
+		when("buildpack ids in a group escape to the same directory", func() {
+			it("returns an error", func() {
+				m := lifecycle.BuildpackMap{
+					"a/b@latest": {ID: "a/b"},
+					"a_b@latest": {ID: "a_b"},
+				}
+				mkfile(t, `groups = [{ buildpacks = [{id = "a/b"}, {id = "a_b"}] }, { buildpacks = [{id = "a/b"}] }]`,
+					filepath.Join(tmpDir, "order.toml"),
+				)
+				_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
+				if err == nil {
+					t.Fatal("Expected error.\n")
+				} else if !strings.Contains(err.Error(), "buildpacks 'a/b' and 'a_b' would both use layers directory 'a_b'") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		})
+	})
+
+	when("version constraints", func() {
+		var (
+			tmpDir string
+			m      lifecycle.BuildpackMap
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m = lifecycle.BuildpackMap{}
+			for _, v := range []string{"1.0.0", "1.2.3", "1.4.0", "2.0.1", "2.1.0-rc.1", "3.0.0", "other"} {
+				m["buildpack1@"+v] = &lifecycle.Buildpack{ID: "buildpack1", Version: v}
+			}
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		readVersion := func(version string) (string, error) {
+			mkfile(t, fmt.Sprintf(`buildpacks = [{id = "buildpack1", version = "%s"}]`, version),
+				filepath.Join(tmpDir, "group.toml"),
+			)
+			group, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{})
+			if err != nil {
+				return "", err
+			}
+			return group.Buildpacks[0].Version, nil
+		}
+
+		it("should resolve the highest version satisfying the constraint", func() {
+			for constraint, expected := range map[string]string{
+				"":              "3.0.0",
+				"latest":        "3.0.0",
+				"other":         "other",
+				"1.2.3":         "1.2.3",
+				"^1.2":          "1.4.0",
+				"~1.2":          "1.2.3",
+				"1.x":           "1.4.0",
+				">=2.0 <3":      "2.0.1",
+				">=2.1.0-rc.0":  "3.0.0",
+				"~2.1.0-rc.0":   "2.1.0-rc.1",
+				"<2":            "1.4.0",
+				"^1.0 || >=3.0": "3.0.0",
+			} {
+				actual, err := readVersion(constraint)
+				if err != nil {
+					t.Fatalf("Unexpected error for '%s': %s\n", constraint, err)
+				}
+				if actual != expected {
+					t.Fatalf("Expected '%s' to resolve to '%s', got '%s'\n", constraint, expected, actual)
+				}
+			}
+		})
+
+		it("should prefer stable versions when no version is given", func() {
+			m["buildpack1@3.1.0-beta"] = &lifecycle.Buildpack{ID: "buildpack1", Version: "3.1.0-beta"}
+			for _, constraint := range []string{"", "latest"} {
+				actual, err := readVersion(constraint)
+				if err != nil {
+					t.Fatalf("Unexpected error for '%s': %s\n", constraint, err)
+				}
+				if actual != "3.0.0" {
+					t.Fatalf("Expected '%s' to resolve to '3.0.0', got '%s'\n", constraint, actual)
+				}
+			}
+		})
+
+		it("should resolve to the highest pre-release when no stable version exists", func() {
+			m = lifecycle.BuildpackMap{}
+			for _, v := range []string{"1.0.0-alpha", "1.0.0-beta", "other"} {
+				m["buildpack1@"+v] = &lifecycle.Buildpack{ID: "buildpack1", Version: v}
+			}
+			actual, err := readVersion("latest")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if actual != "1.0.0-beta" {
+				t.Fatalf("Expected 'latest' to resolve to '1.0.0-beta', got '%s'\n", actual)
+			}
+		})
+
+		it("should list the available versions when the constraint is unsatisfiable", func() {
+			_, err := readVersion("^4")
+			if err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			expected := "no version of buildpack 'buildpack1' satisfies '^4': available versions are [1.0.0, 1.2.3, 1.4.0, 2.0.1, 2.1.0-rc.1, 3.0.0, other]"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+
+		it("should report ambiguous versions", func() {
+			m["buildpack1@v3.0.0"] = &lifecycle.Buildpack{ID: "buildpack1", Version: "v3.0.0"}
+			_, err := readVersion("^3")
+			if err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			if !strings.Contains(err.Error(), "buildpack 'buildpack1@^3' is ambiguous: it matches versions [3.0.0, v3.0.0]: available versions are [1.0.0, 1.2.3, 1.4.0, 2.0.1, 2.1.0-rc.1, 3.0.0, other, v3.0.0]") {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+	})
+
+	when("buildpack API compatibility", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mkfile(t, `buildpacks = [{id = "buildpack1", version = "version1"}]`,
+				filepath.Join(tmpDir, "group.toml"),
+			)
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		it("should accept buildpacks implementing a supported API", func() {
+			for _, api := range []string{"", "0.1", "0.2"} {
+				m := lifecycle.BuildpackMap{
+					"buildpack1@version1": {ID: "buildpack1", Version: "version1", API: api},
+				}
+				if _, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{}); err != nil {
+					t.Fatalf("Unexpected error for API '%s': %s\n", api, err)
+				}
+			}
+		})
+
+		it("should reject buildpacks implementing an unsupported API", func() {
+			for _, api := range []string{"0.3", "1.0"} {
+				m := lifecycle.BuildpackMap{
+					"buildpack1@version1": {ID: "buildpack1", Version: "version1", API: api},
+				}
+				_, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{})
+				if err == nil {
+					t.Fatalf("Expected error for API '%s'.\n", api)
+				}
+				expected := fmt.Sprintf("buildpack 'buildpack1@version1' requires buildpack API %s, but this lifecycle supports 0.1, 0.2", api)
+				if !strings.Contains(err.Error(), expected) {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			}
+		})
+	})
+
+	when("meta-buildpacks", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mkdir(t,
+				filepath.Join(tmpDir, "meta", "v1"),
+				filepath.Join(tmpDir, "buildpack1", "v1"),
+				filepath.Join(tmpDir, "buildpack2", "v2"),
+			)
+			mkBuildpackTOML(t, tmpDir, "buildpack1", "buildpack1-name", "v1")
+			mkBuildpackTOML(t, tmpDir, "buildpack2", "buildpack2-name", "v2")
+			mkfile(t, `groups = [{ buildpacks = [{id = "meta", version = "v1"}] }]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		it("should resolve the order of each meta-buildpack", func() {
+			mkfile(t, fmt.Sprintf(buildpackTOML, "meta", "meta-name", "v1")+`
+[[order]]
+[[order.group]]
+id = "buildpack1"
+version = "v1"
+[[order.group]]
+id = "buildpack2"
+version = "v2"
+optional = true
+
+[[order]]
+[[order.group]]
+id = "buildpack2"
+version = "v2"
+`,
+				filepath.Join(tmpDir, "meta", "v1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bp1 := &lifecycle.Buildpack{ID: "buildpack1", Version: "v1", Name: "buildpack1-name", Dir: filepath.Join(tmpDir, "buildpack1", "v1")}
+			bp2 := &lifecycle.Buildpack{ID: "buildpack2", Version: "v2", Name: "buildpack2-name", Dir: filepath.Join(tmpDir, "buildpack2", "v2")}
+			bp2Optional := *bp2
+			bp2Optional.Optional = true
+			if s := cmp.Diff(actual, lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{
+					ID:      "meta",
+					Version: "v1",
+					Name:    "meta-name",
+					Dir:     filepath.Join(tmpDir, "meta", "v1"),
+					Order: lifecycle.BuildpackOrder{
+						{Buildpacks: []*lifecycle.Buildpack{bp1, &bp2Optional}},
+						{Buildpacks: []*lifecycle.Buildpack{bp2}},
+					},
+				}}},
+			}); s != "" {
+				t.Fatalf("Unexpected order:\n%s\n", s)
+			}
+		})
+
+		it("should error when a meta-buildpack includes itself", func() {
+			mkfile(t, fmt.Sprintf(buildpackTOML, "meta", "meta-name", "v1")+`
+[[order]]
+[[order.group]]
+id = "buildpack1"
+version = "v1"
+[[order.group]]
+id = "meta"
+version = "v1"
+`,
+				filepath.Join(tmpDir, "meta", "v1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{})
+			if err == nil {
+				t.Fatal("Expected error.\n")
+			} else if !strings.Contains(err.Error(), "buildpack 'meta@v1' includes itself: meta@v1 -> meta@v1") {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+	})
+
+	when("stack compatibility", func() {
+		var (
+			tmpDir string
+			m      lifecycle.BuildpackMap
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, fmt.Sprintf(buildpackTOML, "buildpack1", "buildpack1-name", "version1")+`
+[[stacks]]
+id = "some.stack"
+mixins = ["mixin1", "mixin2"]
+
+[[stacks]]
+id = "other.stack"
+`,
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			mkfile(t, `groups = [{ buildpacks = [{id = "buildpack1", version = "version1"}] }]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+			if m, err = lifecycle.NewBuildpackMap(tmpDir); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		it("should read the stacks from buildpack.toml", func() {
+			if s := cmp.Diff(m["buildpack1@version1"].Stacks, []lifecycle.Stack{
+				{ID: "some.stack", Mixins: []string{"mixin1", "mixin2"}},
+				{ID: "other.stack"},
+			}); s != "" {
+				t.Fatalf("Unexpected stacks:\n%s\n", s)
+			}
+		})
+
+		it("should accept buildpacks that support the stack and its mixins", func() {
+			for _, stack := range []lifecycle.Stack{
+				{},
+				{ID: "other.stack"},
+				lifecycle.NewStack("some.stack", "mixin2, mixin1,mixin3"),
+			} {
+				if _, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), stack); err != nil {
+					t.Fatalf("Unexpected error for stack %+v: %s\n", stack, err)
+				}
+			}
+		})
+
+		it("should reject buildpacks that do not support the stack", func() {
+			_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.Stack{ID: "unknown.stack"})
+			if err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			expected := "buildpack 'buildpack1@version1' does not support stack 'unknown.stack': supported stacks are [some.stack, other.stack]"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+
+		it("should reject buildpacks that require mixins missing from the stack", func() {
+			_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"), lifecycle.NewStack("some.stack", "mixin1"))
+			if err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			expected := "buildpack 'buildpack1@version1' requires mixins [mixin2] that are not provided by stack 'some.stack'"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
 	})
 
 	when("#ReadGroup", func() {
@@ -144,7 +570,7 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 			mkfile(t, `buildpacks = [{id = "buildpack1", version = "version1.1"}, {id = "buildpack2", optional = true}]`,
 				filepath.Join(tmpDir, "group.toml"),
 			)
-			actual, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"))
+			actual, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -155,6 +581,24 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should keep the entries each buildpack provides", func() {
+			m := lifecycle.BuildpackMap{
+				"buildpack1@version1.1": {Name: "buildpack1-1.1"},
+			}
+			mkfile(t, `buildpacks = [{id = "buildpack1", version = "version1.1", provides = ["dep1"]}]`,
+				filepath.Join(tmpDir, "group.toml"),
+			)
+			actual, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := cmp.Diff(actual, &lifecycle.BuildpackGroup{
+				Buildpacks: []*lifecycle.Buildpack{{Name: "buildpack1-1.1", Provides: []string{"dep1"}}},
+			}); s != "" {
+				t.Fatalf("Unexpected list:\n%s\n", s)
+			}
+		})
+
 		when("group references a missing buildpack", func() {
 			it("returns an error", func() {
 				m := lifecycle.BuildpackMap{
@@ -164,7 +608,7 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				mkfile(t, `buildpacks = [{id = "buildpack1", version = "version1.1"}, {id = "buildpack2", optional = true}]`,
 					filepath.Join(tmpDir, "group.toml"),
 				)
-				_, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"))
+				_, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"), lifecycle.Stack{})
 				if err == nil {
 					t.Fatal("expected an error")
 				}
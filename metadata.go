@@ -1,7 +1,8 @@
 package lifecycle
 
 const (
-	MetadataLabel = "io.buildpacks.lifecycle.metadata"
+	MetadataLabel      = "io.buildpacks.lifecycle.metadata"
+	CacheMetadataLabel = "io.buildpacks.lifecycle.cache.metadata"
 )
 
 type AppImageMetadata struct {
@@ -43,3 +44,7 @@ type RunImageMetadata struct {
 	TopLayer string `json:"topLayer"`
 	SHA      string `json:"sha"`
 }
+
+type CacheMetadata struct {
+	Buildpacks []BuildpackMetadata `json:"buildpacks"`
+}
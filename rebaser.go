@@ -0,0 +1,71 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle/image"
+)
+
+type Rebaser struct {
+	Context  context.Context
+	Out, Err *log.Logger
+}
+
+func (r *Rebaser) Rebase(workingImage, newBaseImage image.Image) error {
+	found, err := workingImage.Found()
+	if err != nil {
+		return errors.Wrap(err, "looking for image")
+	}
+	if !found {
+		return fmt.Errorf("image '%s' not found or requires authentication to access", workingImage.Name())
+	}
+	label, err := workingImage.Label(MetadataLabel)
+	if err != nil {
+		return errors.Wrap(err, "getting metadata")
+	}
+	var metadata AppImageMetadata
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return errors.Wrapf(err, "image '%s' has incompatible '%s' label", workingImage.Name(), MetadataLabel)
+	}
+	if metadata.RunImage.TopLayer == "" {
+		return fmt.Errorf("image '%s' has no run image top layer in '%s' label", workingImage.Name(), MetadataLabel)
+	}
+
+	r.Out.Printf("rebasing '%s' from top layer '%s' onto '%s'\n", workingImage.Name(), metadata.RunImage.TopLayer, newBaseImage.Name())
+	if err := workingImage.Rebase(metadata.RunImage.TopLayer, newBaseImage); err != nil {
+		return errors.Wrap(err, "rebase app image")
+	}
+
+	metadata.RunImage.TopLayer, err = newBaseImage.TopLayer()
+	if err != nil {
+		return errors.Wrap(err, "get run image top layer SHA")
+	}
+	metadata.RunImage.SHA, err = newBaseImage.Digest()
+	if err != nil {
+		return errors.Wrap(err, "get run image digest")
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.Wrap(err, "marshal metadata")
+	}
+	r.Out.Printf("setting metadata label '%s'\n", MetadataLabel)
+	if err := workingImage.SetLabel(MetadataLabel, string(data)); err != nil {
+		return errors.Wrap(err, "set app image metadata label")
+	}
+
+	if err := checkContext(r.Context); err != nil {
+		return err
+	}
+	r.Out.Println("writing image")
+	sha, err := workingImage.Save()
+	if err != nil {
+		return errors.Wrap(err, "save image")
+	}
+	r.Out.Printf("\n*** Image: %s@%s\n", workingImage.Name(), sha)
+	return nil
+}
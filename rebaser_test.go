@@ -0,0 +1,103 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	h "github.com/buildpack/lifecycle/testhelpers"
+	"github.com/buildpack/lifecycle/testmock"
+)
+
+func TestRebaser(t *testing.T) {
+	spec.Run(t, "Rebaser", testRebaser, spec.Report(report.Terminal{}))
+}
+
+func testRebaser(t *testing.T, when spec.G, it spec.S) {
+	var (
+		rebaser        *lifecycle.Rebaser
+		mockCtrl       *gomock.Controller
+		workingImage   *testmock.MockImage
+		newBaseImage   *testmock.MockImage
+		stdout, stderr *bytes.Buffer
+	)
+
+	it.Before(func() {
+		mockCtrl = gomock.NewController(t)
+		workingImage = testmock.NewMockImage(mockCtrl)
+		newBaseImage = testmock.NewMockImage(mockCtrl)
+		workingImage.EXPECT().Name().Return("some-app-image").AnyTimes()
+		newBaseImage.EXPECT().Name().Return("some-new-run-image").AnyTimes()
+
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		rebaser = &lifecycle.Rebaser{
+			Out: log.New(stdout, "", 0),
+			Err: log.New(stderr, "", 0),
+		}
+	})
+
+	it.After(func() {
+		mockCtrl.Finish()
+	})
+
+	when("#Rebase", func() {
+		when("the image has metadata", func() {
+			it.Before(func() {
+				workingImage.EXPECT().Found().Return(true, nil)
+				workingImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").Return(
+					`{"app": {"sha": "some-app-sha"}, "runImage": {"topLayer": "some-old-top-layer", "sha": "some-old-run-sha"}}`, nil,
+				)
+			})
+
+			it("should rebase onto the new run image and update the metadata", func() {
+				var label string
+				gomock.InOrder(
+					workingImage.EXPECT().Rebase("some-old-top-layer", newBaseImage),
+					newBaseImage.EXPECT().TopLayer().Return("some-new-top-layer", nil),
+					newBaseImage.EXPECT().Digest().Return("some-new-run-sha", nil),
+					workingImage.EXPECT().SetLabel("io.buildpacks.lifecycle.metadata", gomock.Any()).Do(func(_, v string) {
+						label = v
+					}),
+					workingImage.EXPECT().Save().Return("some-digest", nil),
+				)
+
+				h.AssertNil(t, rebaser.Rebase(workingImage, newBaseImage))
+
+				var metadata lifecycle.AppImageMetadata
+				h.AssertNil(t, json.Unmarshal([]byte(label), &metadata))
+				h.AssertEq(t, metadata.App.SHA, "some-app-sha")
+				h.AssertEq(t, metadata.RunImage, lifecycle.RunImageMetadata{
+					TopLayer: "some-new-top-layer",
+					SHA:      "some-new-run-sha",
+				})
+			})
+		})
+
+		when("the image has no run image top layer", func() {
+			it("should return an error", func() {
+				workingImage.EXPECT().Found().Return(true, nil)
+				workingImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").Return(`{}`, nil)
+
+				if err := rebaser.Rebase(workingImage, newBaseImage); err == nil {
+					t.Fatal("Expected error")
+				}
+			})
+		})
+
+		when("the image does not exist", func() {
+			it("should return an error", func() {
+				workingImage.EXPECT().Found().Return(false, nil)
+
+				if err := rebaser.Rebase(workingImage, newBaseImage); err == nil {
+					t.Fatal("Expected error")
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle/fs"
+)
+
+type Retriever struct {
+	Buildpacks []*Buildpack
+	LayersDir  string
+	Context    context.Context
+	Out, Err   *log.Logger
+}
+
+func (r *Retriever) Retrieve(cacheStore Cache) error {
+	metadata, err := cacheStore.RetrieveMetadata()
+	if err != nil {
+		return errors.Wrap(err, "read cache metadata")
+	}
+	if len(metadata.Buildpacks) == 0 {
+		r.Out.Printf("WARNING: cache '%s' is empty\n", cacheStore.Name())
+		return nil
+	}
+
+	for _, bp := range r.Buildpacks {
+		bpMetadata, ok := cacheMetadata(bp.ID, metadata)
+		if !ok {
+			continue
+		}
+		for layerName, layer := range bpMetadata.Layers {
+			if err := checkContext(r.Context); err != nil {
+				return err
+			}
+			if err := r.restoreLayer(cacheStore, bp, layerName, layer); err != nil {
+				return errors.Wrapf(err, "restoring layer '%s/%s'", bp.ID, layerName)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Retriever) restoreLayer(cacheStore Cache, bp *Buildpack, name string, layer LayerMetadata) error {
+	r.Out.Printf("restoring cached layer '%s/%s' with diffID '%s'\n", bp.ID, name, layer.SHA)
+	rc, err := cacheStore.RetrieveLayer(layer.SHA)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	layerPath := filepath.Join(r.LayersDir, bp.EscapedID(), name)
+	if err := os.RemoveAll(layerPath); err != nil {
+		return err
+	}
+	fs := &fs.FS{}
+	if err := fs.Untar(rc, r.LayersDir); err != nil {
+		return err
+	}
+	return writeTOML(layerPath+".toml", layer)
+}
+
+func cacheMetadata(id string, metadata CacheMetadata) (*BuildpackMetadata, bool) {
+	for _, bpMetadata := range metadata.Buildpacks {
+		if bpMetadata.ID == id {
+			return &bpMetadata, true
+		}
+	}
+	return nil, false
+}
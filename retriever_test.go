@@ -0,0 +1,149 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	h "github.com/buildpack/lifecycle/testhelpers"
+)
+
+func TestRetriever(t *testing.T) {
+	spec.Run(t, "Retriever", testRetriever, spec.Report(report.Terminal{}))
+}
+
+func testRetriever(t *testing.T, when spec.G, it spec.S) {
+	var (
+		retriever      *lifecycle.Retriever
+		stdout, stderr *bytes.Buffer
+		tmpDir         string
+		layersDir      string
+		cacheDir       string
+		cacheStore     lifecycle.Cache
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.retriever")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		layersDir = filepath.Join(tmpDir, "layers")
+		cacheDir = filepath.Join(tmpDir, "cache")
+		mkdir(t, layersDir, cacheDir)
+		cacheStore, err = lifecycle.NewVolumeCache(cacheDir)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		retriever = &lifecycle.Retriever{
+			Buildpacks: []*lifecycle.Buildpack{{ID: "buildpack.id"}, {ID: "other.buildpack.id"}},
+			LayersDir:  layersDir,
+			Out:        log.New(stdout, "", 0),
+			Err:        log.New(stderr, "", 0),
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Retrieve", func() {
+		when("the cache is populated", func() {
+			it.Before(func() {
+				var err error
+				mkdir(t,
+					filepath.Join(layersDir, "buildpack.id", "cache-layer"),
+					filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer"),
+					filepath.Join(layersDir, "old.buildpack.id", "old-layer"),
+					filepath.Join(tmpDir, "artifacts"),
+				)
+				mkfile(t, "cached", filepath.Join(layersDir, "buildpack.id", "cache-layer", "file"))
+				mkfile(t, "both", filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer", "file"))
+				mkfile(t, "old", filepath.Join(layersDir, "old.buildpack.id", "old-layer", "file"))
+				mkfile(t, "build = true\ncache = true\n[metadata]\n  key = \"val\"",
+					filepath.Join(layersDir, "buildpack.id", "cache-layer.toml"),
+				)
+				mkfile(t, "launch = true\ncache = true",
+					filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer.toml"),
+				)
+				mkfile(t, "cache = true",
+					filepath.Join(layersDir, "old.buildpack.id", "old-layer.toml"),
+				)
+				cacher := &lifecycle.Cacher{
+					Buildpacks:   []*lifecycle.Buildpack{{ID: "buildpack.id"}, {ID: "other.buildpack.id"}, {ID: "old.buildpack.id"}},
+					ArtifactsDir: filepath.Join(tmpDir, "artifacts"),
+					Out:          log.New(ioutil.Discard, "", 0),
+					Err:          log.New(ioutil.Discard, "", 0),
+				}
+				h.AssertNil(t, cacher.Cache(layersDir, cacheStore))
+				cacheStore, err = lifecycle.NewVolumeCache(cacheDir)
+				h.AssertNil(t, err)
+				h.AssertNil(t, os.RemoveAll(layersDir))
+			})
+
+			it("should restore cached layers for buildpacks in the group", func() {
+				h.AssertNil(t, retriever.Retrieve(cacheStore))
+
+				h.AssertEq(t, rdfile(t, filepath.Join(layersDir, "buildpack.id", "cache-layer", "file")), "cached")
+				if _, err := os.Stat(filepath.Join(layersDir, "other.buildpack.id", "cache-launch-layer")); !os.IsNotExist(err) {
+					t.Fatalf("Expected launch layer not to be restored: %s\n", err)
+				}
+				if _, err := os.Stat(filepath.Join(layersDir, "old.buildpack.id")); !os.IsNotExist(err) {
+					t.Fatalf("Expected layers for buildpack not in group to be skipped: %s\n", err)
+				}
+			})
+
+			it("should restore layer metadata", func() {
+				h.AssertNil(t, retriever.Retrieve(cacheStore))
+
+				h.AssertEq(t,
+					rdfile(t, filepath.Join(layersDir, "buildpack.id", "cache-layer.toml")),
+					"build = true\nlaunch = false\ncache = true\n\n[metadata]\n  key = \"val\"\n",
+				)
+			})
+
+			it("should restore layers into a different layers directory", func() {
+				retriever.LayersDir = filepath.Join(tmpDir, "other-layers")
+
+				h.AssertNil(t, retriever.Retrieve(cacheStore))
+
+				h.AssertEq(t, rdfile(t, filepath.Join(tmpDir, "other-layers", "buildpack.id", "cache-layer", "file")), "cached")
+				if _, err := os.Stat(layersDir); !os.IsNotExist(err) {
+					t.Fatalf("Expected original layers directory not to be written: %s\n", err)
+				}
+			})
+
+			it("should replace the contents of existing layers", func() {
+				mkdir(t, filepath.Join(layersDir, "buildpack.id", "cache-layer"))
+				mkfile(t, "stale", filepath.Join(layersDir, "buildpack.id", "cache-layer", "stale-file"))
+
+				h.AssertNil(t, retriever.Retrieve(cacheStore))
+
+				h.AssertEq(t, rdfile(t, filepath.Join(layersDir, "buildpack.id", "cache-layer", "file")), "cached")
+				if _, err := os.Stat(filepath.Join(layersDir, "buildpack.id", "cache-layer", "stale-file")); !os.IsNotExist(err) {
+					t.Fatalf("Expected stale file to be removed: %s\n", err)
+				}
+			})
+		})
+
+		when("the cache is empty", func() {
+			it("should succeed without restoring layers", func() {
+				h.AssertNil(t, retriever.Retrieve(cacheStore))
+
+				if !strings.Contains(stdout.String(), "is empty") {
+					t.Fatalf("Unexpected output: %s\n", stdout)
+				}
+			})
+		})
+	})
+}
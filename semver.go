@@ -0,0 +1,200 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseSemver parses a version of the form [v]MAJOR[.MINOR[.PATCH]][-PRE][+BUILD].
+// It returns the number of version components that were present.
+func parseSemver(s string) (v semver, parts int, err error) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.pre = s[i+1:]
+		s = s[:i]
+	}
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 {
+		return semver{}, 0, fmt.Errorf("invalid version '%s'", s)
+	}
+	for i, n := range nums {
+		d, err := strconv.Atoi(n)
+		if err != nil || d < 0 {
+			return semver{}, 0, fmt.Errorf("invalid version '%s'", s)
+		}
+		switch i {
+		case 0:
+			v.major = d
+		case 1:
+			v.minor = d
+		case 2:
+			v.patch = d
+		}
+	}
+	return v, len(nums), nil
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	}
+	return strings.Compare(v.pre, o.pre)
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+type comparator struct {
+	op string
+	v  semver
+}
+
+func (c comparator) matches(v semver) bool {
+	d := v.compare(c.v)
+	switch c.op {
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return d == 0
+}
+
+// semverConstraint is a list of alternatives separated by "||", each of
+// which is a list of comparators that must all match.
+type semverConstraint [][]comparator
+
+// parseSemverConstraint parses constraints such as "^1.2", "~1.2.3",
+// ">=2.0 <3", "1.x" and "1.2 || ^2".
+func parseSemverConstraint(s string) (semverConstraint, error) {
+	var out semverConstraint
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(alt)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid constraint '%s'", s)
+		}
+		var cs []comparator
+		for _, f := range fields {
+			c, err := parseComparators(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint '%s': %s", s, err)
+			}
+			cs = append(cs, c...)
+		}
+		out = append(out, cs)
+	}
+	return out, nil
+}
+
+func parseComparators(s string) ([]comparator, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, o) {
+			op, s = o, strings.TrimPrefix(s, o)
+			break
+		}
+	}
+	if s == "*" || s == "x" || s == "X" {
+		if op != "" && op != "=" {
+			return nil, fmt.Errorf("invalid version '%s'", s)
+		}
+		return nil, nil
+	}
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		if p == "*" || p == "x" || p == "X" {
+			if op != "" && op != "=" {
+				return nil, fmt.Errorf("invalid version '%s'", s)
+			}
+			s, op = strings.Join(parts[:i], "."), ""
+			break
+		}
+	}
+	v, n, err := parseSemver(s)
+	if err != nil {
+		return nil, err
+	}
+	next := func(n int) semver {
+		switch n {
+		case 1:
+			return semver{major: v.major + 1}
+		case 2:
+			return semver{major: v.major, minor: v.minor + 1}
+		}
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+	switch op {
+	case "^":
+		upper := n
+		switch {
+		case v.major > 0 || n == 1:
+			upper = 1
+		case v.minor > 0 || n == 2:
+			upper = 2
+		}
+		return []comparator{{">=", v}, {"<", next(upper)}}, nil
+	case "~":
+		upper := 2
+		if n == 1 {
+			upper = 1
+		}
+		return []comparator{{">=", v}, {"<", next(upper)}}, nil
+	case "", "=":
+		if n == 3 {
+			return []comparator{{"=", v}}, nil
+		}
+		return []comparator{{">=", v}, {"<", next(n)}}, nil
+	}
+	return []comparator{{op, v}}, nil
+}
+
+// matches returns true if v satisfies any alternative of the constraint.
+// Pre-release versions only satisfy alternatives that mention a pre-release.
+func (c semverConstraint) matches(v semver) bool {
+	for _, alt := range c {
+		ok := v.pre == ""
+		for _, cmp := range alt {
+			if cmp.v.pre != "" {
+				ok = true
+			}
+		}
+		for _, cmp := range alt {
+			if !cmp.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle/image"
+)
+
+const (
+	StackIDLabel     = "io.buildpacks.stack.id"
+	StackMixinsLabel = "io.buildpacks.stack.mixins"
+	AnyStack         = "*"
+)
+
+type Stack struct {
+	ID     string   `toml:"id"`
+	Mixins []string `toml:"mixins,omitempty"`
+}
+
+// NewStack returns a stack with the provided ID and comma-separated mixins.
+func NewStack(id, mixins string) Stack {
+	stack := Stack{ID: id}
+	for _, m := range strings.Split(mixins, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			stack.Mixins = append(stack.Mixins, m)
+		}
+	}
+	return stack
+}
+
+// ImageStack returns the stack declared by the labels on img.
+func ImageStack(img image.Image) (Stack, error) {
+	id, err := img.Label(StackIDLabel)
+	if err != nil {
+		return Stack{}, errors.Wrapf(err, "read label '%s'", StackIDLabel)
+	}
+	mixins, err := img.Label(StackMixinsLabel)
+	if err != nil {
+		return Stack{}, errors.Wrapf(err, "read label '%s'", StackMixinsLabel)
+	}
+	return NewStack(id, mixins), nil
+}
+
+// checkStack returns an error if bp does not support stack. Buildpacks that
+// declare no stacks and stacks without an ID are always compatible.
+func checkStack(bp *Buildpack, stack Stack) error {
+	if stack.ID == "" || len(bp.Stacks) == 0 {
+		return nil
+	}
+	var ids []string
+	for _, s := range bp.Stacks {
+		if s.ID != stack.ID && s.ID != AnyStack {
+			ids = append(ids, s.ID)
+			continue
+		}
+		if missing := missingMixins(s.Mixins, stack.Mixins); len(missing) > 0 {
+			return fmt.Errorf(
+				"buildpack '%s@%s' requires mixins [%s] that are not provided by stack '%s'",
+				bp.ID, bp.Version, strings.Join(missing, ", "), stack.ID,
+			)
+		}
+		return nil
+	}
+	return fmt.Errorf(
+		"buildpack '%s@%s' does not support stack '%s': supported stacks are [%s]",
+		bp.ID, bp.Version, stack.ID, strings.Join(ids, ", "),
+	)
+}
+
+func missingMixins(required, provided []string) []string {
+	have := map[string]bool{}
+	for _, m := range provided {
+		have[m] = true
+	}
+	var missing []string
+	for _, m := range required {
+		if !have[m] {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
@@ -104,6 +104,10 @@ func (f *FakeImage) ReuseLayer(sha string) error {
 	return nil
 }
 
+func (FakeImage) GetLayer(string) (io.ReadCloser, error) {
+	panic("Not Implemented in Fake")
+}
+
 func (f *FakeImage) Save() (string, error) {
 	f.assertNotAlreadySaved()
 	f.alreadySaved = true
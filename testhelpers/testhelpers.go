@@ -400,6 +400,13 @@ func ComputeSHA256ForPath(t *testing.T, path string, uid int, guid int) string {
 	return layer5sha
 }
 
+func ComputeSHA256ForRelativePath(t *testing.T, baseDir, path string, uid int, guid int) string {
+	hasher := sha256.New()
+	err := (&fs.FS{}).WriteRelativeTarArchive(hasher, baseDir, path, uid, guid)
+	AssertNil(t, err)
+	return hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size())))
+}
+
 func RecursiveCopy(t *testing.T, src, dst string) {
 	t.Helper()
 	fis, err := ioutil.ReadDir(src)
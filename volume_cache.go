@@ -0,0 +1,146 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const CacheMetadataFile = "metadata.json"
+
+type VolumeCache struct {
+	dir          string
+	backupDir    string
+	stagingDir   string
+	committedDir string
+	committed    bool
+}
+
+func NewVolumeCache(dir string) (*VolumeCache, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	c := &VolumeCache{
+		dir:          dir,
+		backupDir:    filepath.Join(dir, "committed-backup"),
+		stagingDir:   filepath.Join(dir, "staging"),
+		committedDir: filepath.Join(dir, "committed"),
+	}
+
+	if err := os.RemoveAll(c.stagingDir); err != nil {
+		return nil, errors.Wrap(err, "reset staging directory")
+	}
+	if err := os.MkdirAll(c.stagingDir, 0777); err != nil {
+		return nil, errors.Wrap(err, "create staging directory")
+	}
+	if err := os.MkdirAll(c.committedDir, 0777); err != nil {
+		return nil, errors.Wrap(err, "create committed directory")
+	}
+	return c, nil
+}
+
+func (c *VolumeCache) Name() string {
+	return c.dir
+}
+
+func (c *VolumeCache) AddLayer(sha, tarPath string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	return copyFile(tarPath, filepath.Join(c.stagingDir, layerTarName(sha)))
+}
+
+func (c *VolumeCache) ReuseLayer(sha string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	name := layerTarName(sha)
+	if err := os.Link(filepath.Join(c.committedDir, name), filepath.Join(c.stagingDir, name)); err != nil && !os.IsExist(err) {
+		return errors.Wrapf(err, "reuse layer '%s' from cache", sha)
+	}
+	return nil
+}
+
+func (c *VolumeCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(c.committedDir, layerTarName(sha)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "retrieve layer '%s' from cache", sha)
+	}
+	return f, nil
+}
+
+func (c *VolumeCache) SetMetadata(metadata CacheMetadata) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.Wrap(err, "marshal metadata")
+	}
+	return ioutil.WriteFile(filepath.Join(c.stagingDir, CacheMetadataFile), data, 0666)
+}
+
+func (c *VolumeCache) RetrieveMetadata() (CacheMetadata, error) {
+	var metadata CacheMetadata
+	data, err := ioutil.ReadFile(filepath.Join(c.committedDir, CacheMetadataFile))
+	if os.IsNotExist(err) {
+		return metadata, nil
+	} else if err != nil {
+		return metadata, errors.Wrap(err, "read cache metadata")
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return CacheMetadata{}, errors.Wrap(err, "incompatible cache metadata")
+	}
+	return metadata, nil
+}
+
+func (c *VolumeCache) Commit() error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.committed = true
+	if err := os.RemoveAll(c.backupDir); err != nil {
+		return errors.Wrap(err, "remove previous backup")
+	}
+	if err := os.Rename(c.committedDir, c.backupDir); err != nil {
+		return errors.Wrap(err, "backup committed cache")
+	}
+	if err := os.Rename(c.stagingDir, c.committedDir); err != nil {
+		if err := os.Rename(c.backupDir, c.committedDir); err != nil {
+			return errors.Wrap(err, "restore committed cache")
+		}
+		return errors.Wrap(err, "commit cache")
+	}
+	return os.RemoveAll(c.backupDir)
+}
+
+func layerTarName(sha string) string {
+	return strings.TrimPrefix(sha, "sha256:") + ".tar"
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
@@ -0,0 +1,126 @@
+package lifecycle_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	h "github.com/buildpack/lifecycle/testhelpers"
+)
+
+func TestVolumeCache(t *testing.T) {
+	spec.Run(t, "VolumeCache", testVolumeCache, spec.Report(report.Terminal{}))
+}
+
+func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir     string
+		cacheDir   string
+		cacheStore *lifecycle.VolumeCache
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.volume-cache")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		cacheDir = filepath.Join(tmpDir, "cache")
+		mkdir(t, cacheDir)
+		cacheStore, err = lifecycle.NewVolumeCache(cacheDir)
+		h.AssertNil(t, err)
+		mkfile(t, "some-layer", filepath.Join(tmpDir, "some-layer.tar"))
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when(".NewVolumeCache", func() {
+		it("should fail when the cache directory does not exist", func() {
+			if _, err := lifecycle.NewVolumeCache(filepath.Join(tmpDir, "missing")); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	})
+
+	when("#RetrieveMetadata", func() {
+		it("should return empty metadata when nothing has been committed", func() {
+			metadata, err := cacheStore.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, metadata, lifecycle.CacheMetadata{})
+		})
+
+		it("should fail when the committed metadata is incompatible", func() {
+			mkfile(t, "not json", filepath.Join(cacheDir, "committed", lifecycle.CacheMetadataFile))
+
+			_, err := cacheStore.RetrieveMetadata()
+			if err == nil || !strings.Contains(err.Error(), "incompatible cache metadata") {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+		})
+	})
+
+	when("#Commit", func() {
+		it("should make staged layers and metadata retrievable", func() {
+			metadata := lifecycle.CacheMetadata{
+				Buildpacks: []lifecycle.BuildpackMetadata{{ID: "buildpack.id", Layers: map[string]lifecycle.LayerMetadata{
+					"layer": {SHA: "sha256:some-sha", Cache: true},
+				}}},
+			}
+			h.AssertNil(t, cacheStore.AddLayer("sha256:some-sha", filepath.Join(tmpDir, "some-layer.tar")))
+			h.AssertNil(t, cacheStore.SetMetadata(metadata))
+
+			if _, err := cacheStore.RetrieveLayer("sha256:some-sha"); err == nil {
+				t.Fatal("Expected staged layer to be unavailable before commit")
+			}
+
+			h.AssertNil(t, cacheStore.Commit())
+
+			nextStore, err := lifecycle.NewVolumeCache(cacheDir)
+			h.AssertNil(t, err)
+			actual, err := nextStore.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, actual, metadata)
+			rc, err := nextStore.RetrieveLayer("sha256:some-sha")
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := ioutil.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "some-layer")
+		})
+
+		it("should keep reused layers and drop the rest", func() {
+			h.AssertNil(t, cacheStore.AddLayer("sha256:reused-sha", filepath.Join(tmpDir, "some-layer.tar")))
+			h.AssertNil(t, cacheStore.AddLayer("sha256:dropped-sha", filepath.Join(tmpDir, "some-layer.tar")))
+			h.AssertNil(t, cacheStore.Commit())
+
+			nextStore, err := lifecycle.NewVolumeCache(cacheDir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, nextStore.ReuseLayer("sha256:reused-sha"))
+			h.AssertNil(t, nextStore.Commit())
+
+			testExists(t, filepath.Join(cacheDir, "committed", "reused-sha.tar"))
+			if _, err := os.Stat(filepath.Join(cacheDir, "committed", "dropped-sha.tar")); !os.IsNotExist(err) {
+				t.Fatalf("Expected layer to be dropped: %s\n", err)
+			}
+		})
+
+		it("should fail to modify the cache after commit", func() {
+			h.AssertNil(t, cacheStore.Commit())
+
+			if err := cacheStore.AddLayer("sha256:some-sha", filepath.Join(tmpDir, "some-layer.tar")); err == nil {
+				t.Fatal("Expected error")
+			}
+			if err := cacheStore.Commit(); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	})
+}